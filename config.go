@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is loaded from the working directory when neither the
+// -config flag nor the configEnv environment variable is given.
+const defaultConfigFile = ".yamlfmt.yaml"
+
+// configEnv names the environment variable with the configuration file used
+// when no -config flag is given.
+const configEnv = "YAMLFMT_CONFIG"
+
+// config holds the settings read from a yamlfmt configuration file.
+type config struct {
+	// SortPaths lists dotted path patterns. If it isn't empty, only the
+	// mappings at matching paths are sorted.
+	SortPaths []string `yaml:"sortPaths"`
+	// KeyOrder maps a dotted path pattern to the keys that are placed first,
+	// in the given order, when sorting a mapping at a matching path. The
+	// remaining keys are sorted alphabetically after them.
+	KeyOrder map[string][]string `yaml:"keyOrder"`
+	// AllowedKeys maps a dotted path pattern to the only keys kept in a
+	// mapping at a matching path. The empty pattern matches the top-level
+	// mapping.
+	AllowedKeys map[string][]string `yaml:"allowedKeys"`
+	// ValueTransforms maps a dotted path pattern to the transform, "lower",
+	// "upper" or "trim", applied to string values at matching paths.
+	ValueTransforms map[string]string `yaml:"valueTransforms"`
+	// KeyTransforms maps a dotted path pattern to the transform applied to
+	// the string keys of mappings at matching paths.
+	KeyTransforms map[string]string `yaml:"keyTransforms"`
+	// SortSequences maps a dotted path pattern to the field by which the
+	// items of sequences of mappings at matching paths are sorted.
+	SortSequences map[string]string `yaml:"sortSequences"`
+	// PreserveQuotesFor lists dotted path patterns of scalar values that
+	// keep their quotes.
+	PreserveQuotesFor []string `yaml:"preserveQuotesFor"`
+	// KeepHeaderComment keeps the comments at the top of every document,
+	// separated from its first entry by a blank line, with -strip-comments.
+	KeepHeaderComment bool `yaml:"keepHeaderComment"`
+	// KeepCommentPrefixes lists the prefixes of the comment lines that
+	// -normalize-comments leaves alone, instead of #! and ##.
+	KeepCommentPrefixes []string `yaml:"keepCommentPrefixes"`
+}
+
+// loadConfig reads the configuration file f. If f is empty, the file named
+// by the configEnv environment variable is read, or else the default
+// configuration file if it exists, and an empty configuration is returned
+// otherwise.
+func loadConfig(f string) (*config, error) {
+	if f == "" {
+		f = os.Getenv(configEnv)
+	}
+	if f == "" {
+		if _, err := os.Stat(defaultConfigFile); err != nil {
+			return &config{}, nil
+		}
+		f = defaultConfigFile
+	}
+
+	r, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	c := &config{}
+	d := yaml.NewDecoder(r)
+	d.KnownFields(true)
+	// An empty or comment-only file has no document, which is an empty
+	// configuration.
+	if err := d.Decode(c); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	env := filepath.Join(dir, "env.yaml")
+	flagged := filepath.Join(dir, "flag.yaml")
+	assert.NoError(t, ioutil.WriteFile(env, []byte("keyOrder:\n  \"\": [env]\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(flagged, []byte("keyOrder:\n  \"\": [flag]\n"), 0644))
+
+	defer os.Setenv(configEnv, os.Getenv(configEnv))
+	os.Setenv(configEnv, env)
+
+	c, err := loadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"": {"env"}}, c.KeyOrder)
+
+	c, err = loadConfig(flagged)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"": {"flag"}}, c.KeyOrder)
+}
+
+func TestLoadConfigEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, content := range []string{"", "# No settings yet.\n"} {
+		f := filepath.Join(dir, "config.yaml")
+		assert.NoError(t, ioutil.WriteFile(f, []byte(content), 0644))
+		c, err := loadConfig(f)
+		assert.NoError(t, err, content)
+		assert.Equal(t, &config{}, c, content)
+	}
+}
+
+func TestLoadConfigKeepCommentPrefixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// An empty list is kept apart from a missing one, which keeps the
+	// default prefixes.
+	f := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(f, []byte("keepCommentPrefixes: []\n"), 0644))
+	c, err := loadConfig(f)
+	assert.NoError(t, err)
+	assert.NotNil(t, c.KeepCommentPrefixes)
+	assert.Empty(t, c.KeepCommentPrefixes)
+}
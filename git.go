@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// changedFiles returns the YAML files in the directory dir that differ from
+// the last commit of the git repository containing it, either in the working
+// tree or in the index, in lexical order. Deleted files are left out.
+func changedFiles(dir string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, args := range [][]string{
+		{"diff", "--name-only", "-z", "--relative", "--diff-filter=d"},
+		{"diff", "--name-only", "-z", "--relative", "--diff-filter=d", "--cached"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			msg := strings.SplitN(strings.TrimSpace(stderr.String()), "\n", 2)[0]
+			if msg == "" {
+				msg = err.Error()
+			}
+			return nil, fmt.Errorf("-only-changed requires a git repository: %s", msg)
+		}
+		// With -z, paths are separated by NUL and written as they are, while
+		// otherwise paths with unusual characters such as café.yaml are
+		// quoted.
+		for _, f := range strings.Split(string(out), "\x00") {
+			if ext := filepath.Ext(f); ext == ".yaml" || ext == ".yml" {
+				seen[filepath.Join(dir, filepath.FromSlash(f))] = true
+			}
+		}
+	}
+
+	files := []string{}
+	for f := range seen {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files, nil
+}
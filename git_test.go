@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = changedFiles(dir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "-only-changed requires a git repository")
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=yamlfmt", "-c", "user.email=yamlfmt@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	for _, f := range []string{"a.yaml", "b.yaml", "c.yml", "café.yaml", "notes.txt"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, f), []byte("a: 1\n"), 0644))
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte("b: 2\na: 1\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c.yml"), []byte("b: 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "café.yaml"), []byte("b: 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("b: 2\n"), 0644))
+	git("add", "c.yml")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "b.yaml")))
+
+	files, err := changedFiles(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "c.yml"), filepath.Join(dir, "café.yaml")}, files)
+}
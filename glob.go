@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+// hasGlobMeta reports whether s contains any glob metacharacters.
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// expandArgs replaces the glob patterns in args by the files they match,
+// leaving other arguments as they are, including existing files whose names
+// contain glob metacharacters. It is an error for a pattern to match no
+// files, unless allowEmpty is set. Hidden files and directories
+// are only matched with includeHidden.
+func expandArgs(args []string, includeHidden bool, allowEmpty bool) ([]string, error) {
+	files := []string{}
+	for _, arg := range args {
+		if !hasGlobMeta(arg) {
+			files = append(files, arg)
+			continue
+		}
+		if _, err := os.Stat(arg); err == nil {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := glob(arg, includeHidden)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 && !allowEmpty {
+			return nil, fmt.Errorf("No files match pattern %q", arg)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// glob returns the files matching pattern, in lexical order. Segments of
+// the slash-separated pattern are matched with path.Match, and a "**"
+// segment matches any number of directories. Files and directories whose
+// names start with a dot are skipped unless includeHidden is set, except in
+// the leading part of the pattern without glob metacharacters. Without a
+// "**" segment, only the directories that the pattern can reach are walked.
+func glob(pattern string, includeHidden bool) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(segments) && !hasGlobMeta(segments[i]) {
+		i++
+	}
+	root := strings.Join(segments[:i], "/")
+	if root == "" && i > 0 {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+
+	rest := segments[i:]
+	deep := false
+	for _, segment := range rest {
+		deep = deep || segment == "**"
+	}
+
+	matches := []string{}
+	err := filepath.Walk(filepath.FromSlash(root), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !includeHidden && p != filepath.FromSlash(root) && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(root), p)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !deep && rel != "." && !reachable(rest, strings.Split(filepath.ToSlash(rel), "/")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if format.Match(rest, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return matches, nil
+	}
+	return matches, err
+}
+
+// reachable reports whether the pattern segments without "**" can match
+// files in the directory with the path segments dir.
+func reachable(segments []string, dir []string) bool {
+	if len(dir) >= len(segments) {
+		return false
+	}
+	for i, name := range dir {
+		if ok, _ := path.Match(segments[i], name); !ok {
+			return false
+		}
+	}
+	return true
+}
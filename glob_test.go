@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, f := range []string{"a.yaml", "sub/b.yaml", "sub/deep/c.yaml", "sub/d.txt"} {
+		p := filepath.Join(dir, f)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, ioutil.WriteFile(p, []byte("a: 1\n"), 0644))
+	}
+
+	files, err := expandArgs([]string{filepath.Join(dir, "**", "*.yaml"), "literal.yaml"}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.yaml"),
+		filepath.Join(dir, "sub", "b.yaml"),
+		filepath.Join(dir, "sub", "deep", "c.yaml"),
+		"literal.yaml",
+	}, files)
+
+	_, err = expandArgs([]string{filepath.Join(dir, "**", "*.json")}, false, false)
+	assert.EqualError(t, err, `No files match pattern "`+filepath.Join(dir, "**", "*.json")+`"`)
+
+	files, err = expandArgs([]string{filepath.Join(dir, "**", "*.json")}, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, files)
+
+	files, err = expandArgs([]string{filepath.Join(dir, "*.yaml"), filepath.Join(dir, "s*", "*.yaml")}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "sub", "b.yaml")}, files)
+}
+
+func TestExpandArgsExistingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	literal := filepath.Join(dir, "[x].yaml")
+	assert.NoError(t, ioutil.WriteFile(literal, []byte("a: 1\n"), 0644))
+	files, err := expandArgs([]string{literal}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{literal}, files)
+}
+
+func TestExpandArgsHidden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, f := range []string{"config.yaml", ".hidden/config.yaml", "sub/.config.yaml"} {
+		p := filepath.Join(dir, f)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, ioutil.WriteFile(p, []byte("a: 1\n"), 0644))
+	}
+	pattern := filepath.Join(dir, "**", "*.yaml")
+
+	files, err := expandArgs([]string{pattern}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "config.yaml")}, files)
+
+	files, err = expandArgs([]string{pattern}, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, ".hidden", "config.yaml"),
+		filepath.Join(dir, "config.yaml"),
+		filepath.Join(dir, "sub", ".config.yaml"),
+	}, files)
+
+	files, err = expandArgs([]string{filepath.Join(dir, ".hidden", "*.yaml")}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, ".hidden", "config.yaml")}, files)
+}
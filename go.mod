@@ -1,8 +1,14 @@
 module github.com/wangkuiyi/yamlfmt
 
-go 1.13
+go 1.20
 
 require (
 	github.com/stretchr/testify v1.5.1
-	gopkg.in/yaml.v3 v3.0.0-20190709130402-674ba3eaed22
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+)
+
+// gzipMagic starts every gzip-compressed stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns in decompressed if it is gzip-compressed, and in
+// otherwise. It reports whether in was compressed.
+func decompress(in []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(in, gzipMagic) {
+		return in, false, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, true, err
+	}
+	defer r.Close()
+	out, err := ioutil.ReadAll(r)
+	return out, true, err
+}
+
+// compressOutput reports whether the output file, which the formatted
+// content of f is written to, is compressed: if its name ends with ".gz",
+// or if it overwrites f and f was compressed.
+func compressOutput(f string, output string, gzipped bool) bool {
+	return strings.HasSuffix(output, ".gz") || output == f && gzipped
+}
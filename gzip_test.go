@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+func gzipped(t *testing.T, content string) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func gunzipped(t *testing.T, f string) string {
+	in, err := ioutil.ReadFile(f)
+	assert.NoError(t, err)
+	r, err := gzip.NewReader(bytes.NewReader(in))
+	if !assert.NoError(t, err, f) {
+		return ""
+	}
+	out, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestFormatFileGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	in := "kind: B\nb: 1\na: 2\n---\nkind: A\n"
+	expected := "kind: A\n---\na: 2\nb: 1\nkind: B\n"
+	f := filepath.Join(dir, "bundle.yaml.gz")
+	assert.NoError(t, ioutil.WriteFile(f, gzipped(t, in), 0644))
+
+	out := filepath.Join(dir, "out.yaml.gz")
+	changed, err := formatFile(f, out, false, false, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, expected, gunzipped(t, out))
+
+	plain := filepath.Join(dir, "out.yaml")
+	_, err = formatFile(f, plain, false, false, format.DefaultOptions())
+	assert.NoError(t, err)
+	content, err := ioutil.ReadFile(plain)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+
+	changed, err = formatFile(f, f, false, false, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, expected, gunzipped(t, f))
+
+	changed, err = formatFile(f, f, false, false, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
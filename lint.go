@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+// lintFiles writes the issues that format.Lint finds in the files to w, one
+// "file:line: message" line each, and reports whether it found any.
+func lintFiles(files []string, w io.Writer) (bool, error) {
+	found := false
+	for _, f := range files {
+		in, _, err := readInput(f)
+		if err != nil {
+			return found, err
+		}
+		issues, err := format.Lint(bytes.NewReader(in))
+		if err != nil {
+			return found, fmt.Errorf("Failed linting YAML stream: %w", fileError(f, err))
+		}
+		for _, issue := range issues {
+			if _, err := fmt.Fprintf(w, "%s:%d: %s\n", displayName(f), issue.Line, issue.Message); err != nil {
+				return found, err
+			}
+		}
+		found = found || len(issues) > 0
+	}
+	return found, nil
+}
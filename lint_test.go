@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bad := filepath.Join(dir, "bad.yaml")
+	good := filepath.Join(dir, "good.yaml")
+	assert.NoError(t, ioutil.WriteFile(bad, []byte("b: 1 \nb: 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(good, []byte("b: 1\na: 2\n"), 0644))
+
+	var out bytes.Buffer
+	found, err := lintFiles([]string{good, bad}, &out)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, bad+":1: trailing whitespace\n"+bad+":2: key b collides with key b on line 1\n", out.String())
+
+	out.Reset()
+	found, err = lintFiles([]string{good}, &out)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Empty(t, out.String())
+
+	content, err := ioutil.ReadFile(bad)
+	assert.NoError(t, err)
+	assert.Equal(t, "b: 1 \nb: 2\n", string(content))
+}
@@ -0,0 +1,164 @@
+package format
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// expandAliases replaces every alias below node by a copy of the node it
+// refers to, resolves merge keys into the mappings that contain them, and
+// drops the then unused anchors. It fails on an alias that refers to a node
+// containing it, which can't be expanded.
+func expandAliases(node *yaml.Node) error {
+	for i, child := range node.Content {
+		if child.Kind&yaml.AliasNode > 0 {
+			c, err := copyNode(child.Alias, map[*yaml.Node]bool{})
+			if err != nil {
+				return err
+			}
+			node.Content[i] = c
+		}
+		if err := expandAliases(node.Content[i]); err != nil {
+			return err
+		}
+	}
+	node.Anchor = ""
+	if node.Kind&yaml.MappingNode > 0 {
+		mergeKeys(node)
+	}
+	return nil
+}
+
+// copyNode returns a deep copy of node in which aliases are replaced by
+// copies of the nodes they refer to. Copying records the nodes being copied
+// in active to detect aliases to a node from within itself.
+func copyNode(node *yaml.Node, active map[*yaml.Node]bool) (*yaml.Node, error) {
+	if node.Kind&yaml.AliasNode > 0 {
+		if active[node.Alias] {
+			return nil, fmt.Errorf("line %d: alias *%s refers to a node that contains it", node.Line, node.Value)
+		}
+		return copyNode(node.Alias, active)
+	}
+	active[node] = true
+	defer delete(active, node)
+	c := *node
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		cc, err := copyNode(child, active)
+		if err != nil {
+			return nil, err
+		}
+		c.Content[i] = cc
+	}
+	return &c, nil
+}
+
+// mergeKeys replaces the "<<" merge keys of a mapping by the entries of the
+// mappings they merge. Keys of the mapping itself take precedence over
+// merged ones, and earlier merged mappings over later ones.
+func mergeKeys(node *yaml.Node) {
+	own, err := tuples(node.Content)
+	if err != nil {
+		return
+	}
+	seen := map[mapKey]bool{}
+	for _, tuple := range own {
+		if !isMergeKey(tuple.Key) {
+			seen[keyOf(tuple.Key)] = true
+		}
+	}
+	merged := []tupleItem{}
+	for _, tuple := range own {
+		if !isMergeKey(tuple.Key) {
+			merged = append(merged, tuple)
+			continue
+		}
+		sources := []*yaml.Node{tuple.Value}
+		if tuple.Value.Kind&yaml.SequenceNode > 0 {
+			sources = tuple.Value.Content
+		}
+		for _, source := range sources {
+			inherited, err := tuples(source.Content)
+			if source.Kind&yaml.MappingNode == 0 || err != nil {
+				continue
+			}
+			for _, t := range inherited {
+				if !seen[keyOf(t.Key)] {
+					seen[keyOf(t.Key)] = true
+					merged = append(merged, t)
+				}
+			}
+		}
+	}
+	node.Content = contents(merged)
+}
+
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind&yaml.ScalarNode > 0 && node.ShortTag() == "!!merge"
+}
+
+// untagMergeKeys removes the tags of the merge keys "<<" of node and the
+// nodes below it, and returns a function that puts them back. The encoder
+// doesn't resolve "<<" to !!merge, so it writes a tagged merge key as
+// "!!merge <<", while it writes an untagged one as "<<", which decodes as a
+// merge key again.
+func untagMergeKeys(node *yaml.Node) func() {
+	tags := map[*yaml.Node]string{}
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Kind&yaml.MappingNode > 0 {
+			for i := 0; i < len(node.Content); i += 2 {
+				if key := node.Content[i]; isMergeKey(key) && key.Value == "<<" {
+					tags[key] = key.Tag
+					key.Tag = ""
+				}
+			}
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+	return func() {
+		for key, tag := range tags {
+			key.Tag = tag
+		}
+	}
+}
+
+// orderAnchors makes the anchor of every node come before the aliases that
+// refer to it in the order the encoder writes node, which sorting can
+// change. The first alias of a node that comes before it takes the node's
+// content and anchor, and the node takes the place of the alias. Comments
+// stay where they are.
+func orderAnchors(node *yaml.Node) {
+	defined := map[*yaml.Node]bool{}
+	moved := map[*yaml.Node]*yaml.Node{}
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Kind&yaml.AliasNode > 0 {
+			if to, ok := moved[node.Alias]; ok {
+				node.Alias = to
+			}
+			target := node.Alias
+			if defined[target] {
+				return
+			}
+			alias := *node
+			*node = *target
+			node.HeadComment, node.LineComment, node.FootComment = alias.HeadComment, alias.LineComment, alias.FootComment
+			alias.Alias = node
+			alias.HeadComment, alias.LineComment, alias.FootComment = target.HeadComment, target.LineComment, target.FootComment
+			*target = alias
+			moved[target] = node
+		}
+		if node.Anchor != "" {
+			defined[node] = true
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+}
@@ -0,0 +1,83 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// canonicalOptions returns opts with the options that decide the layout and
+// the styles of the output replaced by those of the canonical form. Options
+// that change the content, such as AllowedKeys and PruneNull, are kept.
+func canonicalOptions(opts Options) Options {
+	opts.Indent = 2
+	opts.SortDocuments = true
+	opts.ReverseDocuments = false
+	opts.GroupDocuments = false
+	opts.SortByNamespace = true
+	opts.SortByName = true
+	opts.SortKeys = true
+	opts.DescendingKeys = false
+	opts.SortLabelsByName = false
+	opts.KeepRegions = false
+	opts.SortDepth = 0
+	opts.SortPaths = nil
+	opts.KeyOrder = nil
+	opts.NormalizeStyles = true
+	opts.PreserveQuotes = nil
+	opts.KeepFlow = false
+	opts.FlowFoldThreshold = 0
+	opts.SeqStyle = ""
+	opts.SeqIndentStyle = ""
+	opts.IndentStyle = ""
+	opts.MinimalDiff = false
+	opts.ExpandAliases = true
+	opts.NormalizeFloats = true
+	opts.NullStyle = "null"
+	opts.NormalizeComments = false
+	opts.KeepHeaderComment = false
+	opts.ExplicitStart = false
+	opts.ExplicitEnd = false
+	return opts
+}
+
+// canonicalNode removes the anchor of the node at item and writes a scalar
+// in the one form that the canonical output uses for its value: integers in
+// decimal, booleans and special floats in lower case, and strings plain if
+// that keeps them strings or double-quoted otherwise. The encoder chooses
+// the style of strings with line breaks. The tag of a scalar is only kept if
+// that form doesn't resolve to it anyway, so that !!str 1 is written as "1"
+// and !!int "1" as 1.
+func canonicalNode(item *queueItem) {
+	node := item.Node
+	node.Anchor = ""
+	if node.Kind&yaml.ScalarNode == 0 {
+		return
+	}
+
+	tag := node.ShortTag()
+	switch tag {
+	case "!!int", "!!bool":
+		var v interface{}
+		if err := node.Decode(&v); err == nil {
+			node.Value = fmt.Sprint(v)
+		}
+	case "!!float":
+		if strings.HasPrefix(node.Value, ".") || strings.HasPrefix(node.Value, "-.") || strings.HasPrefix(node.Value, "+.") {
+			node.Value = strings.ToLower(strings.TrimPrefix(node.Value, "+"))
+		}
+	}
+	node.Style &^= yaml.LiteralStyle | yaml.FoldedStyle | yaml.SingleQuotedStyle | yaml.DoubleQuotedStyle
+	if tag == "!!str" {
+		block := strings.Contains(node.Value, "\n")
+		if !block && !(unquotable(node) && (!item.Key || plainKey(node.Value))) {
+			node.Style |= yaml.DoubleQuotedStyle
+		}
+	}
+	implicit := &yaml.Node{Kind: yaml.ScalarNode, Style: node.Style &^ yaml.TaggedStyle, Value: node.Value}
+	if node.Style&yaml.TaggedStyle > 0 && implicit.ShortTag() == tag {
+		node.Style &^= yaml.TaggedStyle
+		node.Tag = ""
+	}
+}
@@ -0,0 +1,182 @@
+package format
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// attachFootComments moves foot comments to the keys they belong to. The
+// decoder attaches a comment that follows a block to the last node in that
+// block, even when the comment is indented less than that node and so
+// belongs to an enclosing key, where it has to stay when mappings are
+// sorted. The lines of the source are used to find the comment's column.
+func attachFootComments(node *yaml.Node, lines []string, owners []*yaml.Node) {
+	if node.Kind&yaml.MappingNode > 0 {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			hoistFootComment(key, lines, owners)
+			attachFootComments(node.Content[i+1], lines, append(owners[:len(owners):len(owners)], key))
+		}
+		return
+	}
+	for _, child := range node.Content {
+		hoistFootComment(child, lines, owners)
+		attachFootComments(child, lines, owners)
+	}
+}
+
+// hoistFootComment moves the foot comment lines of node that are indented
+// less than node, and all lines after them, to the innermost owner that is
+// not indented more than the first of these lines.
+func hoistFootComment(node *yaml.Node, lines []string, owners []*yaml.Node) {
+	if node.FootComment == "" || node.Line == 0 || len(owners) == 0 {
+		return
+	}
+	comment := strings.Split(node.FootComment, "\n")
+	columns := commentColumns(comment, lines, node.Line)
+	for i, column := range columns {
+		if column == 0 || column >= node.Column {
+			continue
+		}
+		for j := len(owners) - 1; j >= 0; j-- {
+			if owners[j].Column <= column {
+				hoisted := strings.Join(comment[i:], "\n")
+				if owners[j].FootComment != "" {
+					hoisted += "\n" + owners[j].FootComment
+				}
+				owners[j].FootComment = hoisted
+				node.FootComment = strings.Join(comment[:i], "\n")
+				return
+			}
+		}
+		return
+	}
+}
+
+// commentColumns returns the column of each of the comment lines, looking
+// for them in lines after the line line. The column is 0 for blank comment
+// lines and for lines that weren't found.
+func commentColumns(comment []string, lines []string, line int) []int {
+	columns := make([]int, len(comment))
+	n := line
+	for i, c := range comment {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		for n < len(lines) && strings.TrimSpace(lines[n]) != c {
+			n++
+		}
+		if n == len(lines) {
+			break
+		}
+		columns[i] = len(lines[n]) - len(strings.TrimLeft(lines[n], " ")) + 1
+		n++
+	}
+	return columns
+}
+
+// stripComments removes the comments of node and the nodes below it.
+func stripComments(node *yaml.Node) {
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	for _, child := range node.Content {
+		stripComments(child)
+	}
+}
+
+// normalizeComments puts exactly one space after the # of the comment lines of
+// node, so #foo and #  foo both become # foo. Lines starting with one of keep
+// are left alone.
+func normalizeComments(node *yaml.Node, keep []string) {
+	node.HeadComment = normalizeComment(node.HeadComment, keep)
+	node.LineComment = normalizeComment(node.LineComment, keep)
+	node.FootComment = normalizeComment(node.FootComment, keep)
+}
+
+func normalizeComment(comment string, keep []string) string {
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		text := strings.TrimLeft(line, " \t")
+		if !strings.HasPrefix(text, "#") || hasPrefix(text, keep) {
+			continue
+		}
+		if body := strings.TrimLeft(text[1:], " \t"); body != "" {
+			lines[i] = line[:len(line)-len(text)] + "# " + body
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hasPrefix reports whether s starts with one of prefixes.
+func hasPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// regionMarker matches the comment lines that start and end regions, such as
+// "# region web" and "# endregion".
+var regionMarker = regexp.MustCompile(`(?i)^#\s*(end)?region\b`)
+
+// sortRegions sorts tuples with less within the regions that region markers
+// delimit, and within the runs of keys between regions, keeping the regions
+// and runs in their order. A run ends before a key whose head comment has a
+// marker, and after a key whose foot comment has one.
+func sortRegions(tuples []tupleItem, less func(a *yaml.Node, b *yaml.Node) bool) {
+	start := 0
+	for i := range tuples {
+		if i+1 == len(tuples) || hasMarker(tuples[i].Key.FootComment) || hasMarker(tuples[i+1].Key.HeadComment) {
+			sortRun(tuples[start:i+1], less)
+			start = i + 1
+		}
+	}
+}
+
+// sortRun sorts the keys of a run with less and keeps the markers at its
+// ends: the lines of the head comment of its first key up to the last
+// marker move to the new first key, and the foot comment of its last key,
+// if it has a marker, to the new last key.
+func sortRun(run []tupleItem, less func(a *yaml.Node, b *yaml.Node) bool) {
+	first, last := run[0].Key, run[len(run)-1].Key
+	head := []string{}
+	lines := strings.Split(first.HeadComment, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if regionMarker.MatchString(strings.TrimLeft(lines[i], " \t")) {
+			head = lines[:i+1]
+			first.HeadComment = strings.Join(lines[i+1:], "\n")
+			break
+		}
+	}
+	foot := ""
+	if hasMarker(last.FootComment) {
+		foot, last.FootComment = last.FootComment, ""
+	}
+
+	sort.Slice(run, func(i, j int) bool {
+		return less(run[i].Key, run[j].Key)
+	})
+
+	first, last = run[0].Key, run[len(run)-1].Key
+	if len(head) > 0 {
+		first.HeadComment = strings.Join(append(head, first.HeadComment), "\n")
+		first.HeadComment = strings.TrimRight(first.HeadComment, "\n")
+	}
+	if foot != "" {
+		last.FootComment = strings.TrimLeft(last.FootComment+"\n"+foot, "\n")
+	}
+}
+
+func hasMarker(comment string) bool {
+	for _, line := range strings.Split(comment, "\n") {
+		if regionMarker.MatchString(strings.TrimLeft(line, " \t")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// debugNode is the JSON form of a node printed by printNodeJSON.
+type debugNode struct {
+	Path   string   `json:"path"`
+	Kind   string   `json:"kind"`
+	Tag    string   `json:"tag"`
+	Value  string   `json:"value"`
+	Style  []string `json:"style"`
+	Indent int      `json:"indent"`
+}
+
+// printNode writes node to w as a line of text, indented by its depth.
+func printNode(w io.Writer, node *yaml.Node, path []string, indent int) {
+	i := 0
+	for i < indent {
+		fmt.Fprint(w, "  ")
+		i++
+	}
+	fmt.Fprint(w, "Node .")
+	fmt.Fprint(w, strings.Join(path, "."))
+	fmt.Fprint(w, ": ")
+	fmt.Fprint(w, node.Tag)
+	fmt.Fprint(w, " ")
+	fmt.Fprint(w, node.Value)
+	fmt.Fprint(w, " ")
+	for _, name := range kindNames(node) {
+		fmt.Fprint(w, name+" ")
+	}
+	for _, name := range styleNames(node) {
+		fmt.Fprint(w, name+" ")
+	}
+	fmt.Fprintln(w, "")
+}
+
+// printNodeJSON writes node to w as a single line JSON object.
+func printNodeJSON(w io.Writer, node *yaml.Node, path []string, indent int) {
+	b, err := json.Marshal(debugNode{
+		Path:   strings.Join(path, "."),
+		Kind:   strings.Join(kindNames(node), " "),
+		Tag:    node.Tag,
+		Value:  node.Value,
+		Style:  styleNames(node),
+		Indent: indent,
+	})
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, string(b))
+}
+
+// printDropped writes the path of a key dropped by AllowedKeys to the debug
+// output, as text or as a JSON object with a "dropped" field.
+func printDropped(opts *Options, path []string) {
+	if opts.DebugFormat != "json" {
+		fmt.Fprintf(opts.DebugOutput, "Dropped key .%s\n", strings.Join(path, "."))
+		return
+	}
+	b, err := json.Marshal(map[string]string{"dropped": strings.Join(path, ".")})
+	if err != nil {
+		fmt.Fprintln(opts.DebugOutput, err)
+		return
+	}
+	fmt.Fprintln(opts.DebugOutput, string(b))
+}
+
+func kindNames(node *yaml.Node) []string {
+	names := []string{}
+	if node.Kind&yaml.DocumentNode > 0 {
+		names = append(names, "DocumentNode")
+	}
+	if node.Kind&yaml.SequenceNode > 0 {
+		names = append(names, "SequenceNode")
+	}
+	if node.Kind&yaml.MappingNode > 0 {
+		names = append(names, "MappingNode")
+	}
+	if node.Kind&yaml.ScalarNode > 0 {
+		names = append(names, "ScalarNode")
+	}
+	if node.Kind&yaml.AliasNode > 0 {
+		names = append(names, "AliasNode")
+	}
+	return names
+}
+
+func styleNames(node *yaml.Node) []string {
+	names := []string{}
+	if node.Style&yaml.TaggedStyle > 0 {
+		names = append(names, "TaggedStyle")
+	}
+	if node.Style&yaml.DoubleQuotedStyle > 0 {
+		names = append(names, "DoubleQuotedStyle")
+	}
+	if node.Style&yaml.SingleQuotedStyle > 0 {
+		names = append(names, "SingleQuotedStyle")
+	}
+	if node.Style&yaml.LiteralStyle > 0 {
+		names = append(names, "LiteralStyle")
+	}
+	if node.Style&yaml.FoldedStyle > 0 {
+		names = append(names, "FoldedStyle")
+	}
+	if node.Style&yaml.FlowStyle > 0 {
+		names = append(names, "FlowStyle")
+	}
+	return names
+}
@@ -0,0 +1,48 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugText(t *testing.T) {
+	var trace bytes.Buffer
+	opts := DefaultOptions()
+	opts.Debug = true
+	opts.DebugOutput = &trace
+	assert.NoError(t, Format(strings.NewReader("name: 'app'\n"), ioutil.Discard, opts))
+	assert.Equal(t, `Node .:   DocumentNode 
+  Node .: !!map  MappingNode 
+    Node .: !!str name ScalarNode 
+    Node .name: !!str app ScalarNode SingleQuotedStyle 
+`, trace.String())
+}
+
+func TestDebugJSON(t *testing.T) {
+	var trace bytes.Buffer
+	opts := DefaultOptions()
+	opts.Debug = true
+	opts.DebugFormat = "json"
+	opts.DebugOutput = &trace
+	assert.NoError(t, Format(strings.NewReader("name: 'app'\n"), ioutil.Discard, opts))
+
+	nodes := []debugNode{}
+	s := bufio.NewScanner(&trace)
+	for s.Scan() {
+		var n debugNode
+		assert.NoError(t, json.Unmarshal(s.Bytes(), &n))
+		nodes = append(nodes, n)
+	}
+	assert.Equal(t, []debugNode{
+		{Path: "", Kind: "DocumentNode", Tag: "", Value: "", Style: []string{}, Indent: 0},
+		{Path: "", Kind: "MappingNode", Tag: "!!map", Value: "", Style: []string{}, Indent: 1},
+		{Path: "", Kind: "ScalarNode", Tag: "!!str", Value: "name", Style: []string{}, Indent: 2},
+		{Path: "name", Kind: "ScalarNode", Tag: "!!str", Value: "app", Style: []string{"SingleQuotedStyle"}, Indent: 2},
+	}, nodes)
+}
@@ -0,0 +1,41 @@
+package format
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dedupDocuments returns docs without the documents that are equal to an
+// earlier one. Documents are equal if they encode to the same text or, with
+// ignoreComments, if they decode to the same data.
+func dedupDocuments(docs []*yaml.Node, ignoreComments bool) ([]*yaml.Node, error) {
+	seen := map[string]bool{}
+	kept := []*yaml.Node{}
+	for _, doc := range docs {
+		key, err := documentKey(doc, ignoreComments)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, doc)
+	}
+	return kept, nil
+}
+
+// documentKey returns a text that is equal for documents that
+// dedupDocuments considers equal.
+func documentKey(doc *yaml.Node, ignoreComments bool) (string, error) {
+	if ignoreComments {
+		var v interface{}
+		if err := doc.Decode(&v); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%#v", v), nil
+	}
+	b, err := yaml.Marshal(doc)
+	return string(b), err
+}
@@ -0,0 +1,110 @@
+package format
+
+import (
+	"regexp"
+	"strings"
+)
+
+// leadingDirectives returns the directives, such as %YAML and %TAG, that
+// precede the first document of the stream with the given lines.
+func leadingDirectives(lines []string) []string {
+	directives := []string{}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "%") {
+			break
+		}
+		directives = append(directives, strings.TrimRight(line, " \t\r"))
+	}
+	return directives
+}
+
+// explicitStart reports whether the first document of the stream with the
+// given lines starts with a "---" marker.
+func explicitStart(lines []string) bool {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(line, "%") {
+			continue
+		}
+		return isStartMarker(line)
+	}
+	return false
+}
+
+// isStartMarker reports whether line is a "---" marker that starts a
+// document, possibly followed by content or a carriage return.
+func isStartMarker(line string) bool {
+	line = strings.TrimSuffix(line, "\r")
+	return line == "---" || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "---\t")
+}
+
+// withoutVersion returns source with the %YAML directive before its first
+// document replaced by an empty line. The decoder rejects versions other
+// than 1.1, while formatting doesn't depend on the version.
+func withoutVersion(source []byte) []byte {
+	lines := strings.Split(string(source), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "%") {
+			break
+		}
+		if strings.HasPrefix(line, "%YAML") {
+			lines[i] = ""
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// tagDirective matches a %TAG directive such as
+// "%TAG !e! tag:example.com,2000:", capturing its handle and its prefix.
+var tagDirective = regexp.MustCompile(`^%TAG[ \t]+(!(?:[0-9A-Za-z-]*!)?)[ \t]+(\S+)`)
+
+// verbatimTag matches a tag that the encoder writes verbatim, such as
+// "!<tag:example.com,2000:app>", capturing what precedes it and the tag.
+var verbatimTag = regexp.MustCompile(`(^|[ \[{,])!<([^>]*)>`)
+
+// tagSuffix matches the characters that a tag written with a handle may
+// end with.
+var tagSuffix = regexp.MustCompile(`^[0-9A-Za-z\-#;/?:@&=+$_.~*'()%]+$`)
+
+// withTagHandles rewrites the tags that the encoder wrote verbatim in the
+// encoded document text with the handles of the %TAG directives before it,
+// so that "!<tag:example.com,2000:app>" becomes "!e!app" again after
+// "%TAG !e! tag:example.com,2000:". The decoder expands the handles, and the
+// encoder doesn't know the directives. Block scalars are left as they are.
+func withTagHandles(text string, directives []string) string {
+	handles := [][]string{}
+	for _, directive := range directives {
+		if m := tagDirective.FindStringSubmatch(directive); m != nil {
+			handles = append(handles, m[1:])
+		}
+	}
+	if len(handles) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	body := blockScalarLines(lines)
+	for i, line := range lines {
+		if body[i] {
+			continue
+		}
+		lines[i] = verbatimTag.ReplaceAllStringFunc(line, func(tag string) string {
+			m := verbatimTag.FindStringSubmatch(tag)
+			for _, handle := range handles {
+				suffix := strings.TrimPrefix(m[2], handle[1])
+				if suffix != m[2] && tagSuffix.MatchString(suffix) {
+					return m[1] + handle[0] + suffix
+				}
+			}
+			return tag
+		})
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,34 @@
+package format
+
+import (
+	"fmt"
+)
+
+// FormatError is the error returned by Format for a document that fails to
+// format or has a syntax error. Errors of the whole stream, such as invalid
+// options, are returned as they are.
+type FormatError struct {
+	// File is the name of the file that contains the document. Format leaves
+	// it empty for callers that know the name to fill in.
+	File string
+	// DocIndex is the position of the document in the input, starting at 1.
+	DocIndex int
+	// Cause is the error that the document failed with.
+	Cause error
+	// Syntax reports whether Cause is a syntax error, which names the line
+	// of the stream that it is on.
+	Syntax bool
+}
+
+func (e *FormatError) Error() string {
+	msg := fmt.Sprintf("document %d: %v", e.DocIndex, e.Cause)
+	if e.File != "" {
+		msg = e.File + ": " + msg
+	}
+	return msg
+}
+
+// Unwrap returns the cause of e.
+func (e *FormatError) Unwrap() error {
+	return e.Cause
+}
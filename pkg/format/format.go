@@ -0,0 +1,742 @@
+// Package format formats YAML streams: it sorts documents and mapping keys
+// and normalizes node styles.
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Options controls how Format formats a YAML stream.
+type Options struct {
+	// Indent is the number of spaces used per indentation level.
+	Indent int
+	// Canonical writes the one canonical form of the input, for example to
+	// hash it: without comments and anchors, with an Indent of 2, with all
+	// documents and keys sorted, aliases expanded, and scalars in one form
+	// per value. The options for layout and styles are ignored, while those
+	// that change the content still apply. It can't be combined with Path,
+	// since only the whole input has a canonical form.
+	Canonical bool
+	// Debug prints every visited node to DebugOutput.
+	Debug bool
+	// DebugOutput receives the debug output. A nil DebugOutput means
+	// os.Stderr.
+	DebugOutput io.Writer
+	// DebugFormat is the format of the debug output, either "text" or
+	// "json". An empty DebugFormat means "text".
+	DebugFormat string
+	// SortDocuments sorts the documents of a stream by kind, namespace and
+	// name.
+	SortDocuments bool
+	// ReverseDocuments reverses the order of SortDocuments. Documents that
+	// compare equal keep their order in the input.
+	ReverseDocuments bool
+	// GroupDocuments changes the order of SortDocuments: documents are
+	// grouped by kind, with the groups in the order their kinds first appear
+	// in the stream, and only sorted by namespace and name within a group.
+	GroupDocuments bool
+	// SortByNamespace and SortByName make SortDocuments compare documents
+	// of the same kind by metadata.namespace and metadata.name. Documents
+	// that compare equal keep their order in the input.
+	SortByNamespace bool
+	SortByName      bool
+	// SortAnnotation, if not empty, names an annotation in
+	// metadata.annotations, such as config.kubernetes.io/index, whose
+	// numeric values order the documents that SortDocuments otherwise
+	// considers equal. Documents with a number come before those without.
+	SortAnnotation string
+	// DedupDocuments drops every document that is equal to an earlier one
+	// once the documents are sorted and formatted, including comments unless
+	// DedupIgnoreComments is set.
+	DedupDocuments bool
+	// DedupIgnoreComments makes DedupDocuments compare the decoded data of
+	// documents, so that documents that only differ in comments or styles
+	// are also dropped.
+	DedupIgnoreComments bool
+	// MergeDocuments deep-merges the documents with the same kind, namespace
+	// and name, such as patches of one resource, into the first of them.
+	// Values of later documents override earlier ones, except that mappings
+	// are merged. It can't be combined with CheckSemantics.
+	MergeDocuments bool
+	// MergeSequences is how MergeDocuments merges sequences: "replace", the
+	// default, keeps the later sequence and "append" concatenates them.
+	MergeSequences string
+	// SortKeys sorts the keys of every mapping.
+	SortKeys bool
+	// DescendingKeys sorts keys in descending order. Keys listed in KeyOrder
+	// still come first, in the order given.
+	DescendingKeys bool
+	// SortLabelsByName sorts the keys of mappings at metadata.labels and
+	// metadata.annotations, at any depth, by the name after their last "/"
+	// and then by the prefix before it, such as app.kubernetes.io, ignoring
+	// case.
+	SortLabelsByName bool
+	// KeepRegions sorts keys only within the regions that comments such as
+	// "# region web" and "# endregion" delimit, and within the runs of keys
+	// between regions, which keep their order. The markers stay at the
+	// start and the end of their regions.
+	KeepRegions bool
+	// SortDepth, if positive, limits SortKeys to mappings nested at most this
+	// many levels deep, where the top-level mapping of a document is at
+	// level 1 and every mapping or sequence adds a level.
+	SortDepth int
+	// SortPaths, if not empty, replaces SortKeys and SortDepth: only the
+	// mappings at a path matching one of these dotted path patterns, as
+	// matched by Match, are sorted.
+	SortPaths []string
+	// KeyOrder maps a dotted path pattern, as matched by Match, to keys that
+	// are placed first, in the given order, when sorting a mapping at a
+	// matching path.
+	KeyOrder map[string][]string
+	// AllowedKeys maps a dotted path pattern, as matched by Match, to the only
+	// keys kept in a mapping at a matching path. Other keys are removed with
+	// their values. The empty pattern matches the top-level mapping.
+	AllowedKeys map[string][]string
+	// ValueTransforms maps a dotted path pattern, as matched by Match, to the
+	// transform applied to string values at a matching path: "lower" and
+	// "upper" change their case and "trim" removes surrounding spaces.
+	// Mapping keys are transformed by KeyTransforms instead.
+	ValueTransforms map[string]string
+	// KeyTransforms maps a dotted path pattern, as matched by Match, to the
+	// transform, as in ValueTransforms, applied to the string keys of the
+	// mappings at a matching path. Keys that are equal once transformed are
+	// an error.
+	KeyTransforms map[string]string
+	// SortSequences maps a dotted path pattern, as matched by Match, to the
+	// field by which the items of sequences of mappings at a matching path
+	// are sorted, such as the name of environment variables. Items without
+	// the field come last.
+	SortSequences map[string]string
+	// NormalizeStyles strips quotes that don't change the type of a scalar
+	// and, unless KeepFlow is set, turns flow style into block style.
+	NormalizeStyles bool
+	// PreserveQuotes lists dotted path patterns, as matched by Match, of
+	// scalar values that keep their quotes with NormalizeStyles.
+	PreserveQuotes []string
+	// KeepFlow keeps the flow style of sequences and mappings.
+	KeepFlow bool
+	// KeepFlowMax, if positive, limits KeepFlow to sequences of at most this
+	// many items.
+	KeepFlowMax int
+	// FlowFoldThreshold, if positive, keeps flow sequences of at most this
+	// many items in flow style and turns longer ones into block style, also
+	// without KeepFlow or NormalizeStyles. Flow mappings aren't affected.
+	FlowFoldThreshold int
+	// SeqStyle, if "block" or "flow", renders all sequences in that style,
+	// regardless of their style in the input and of KeepFlow.
+	SeqStyle string
+	// SeqIndentStyle places the dashes of block sequences that are values of
+	// mapping keys relative to their keys. By default, they are indented by
+	// Indent-2 columns, which is flush with the key for an Indent of 2. With
+	// "flush", they are flush with the key, with "indent" they are indented
+	// by Indent, and with "nested" the sequences that aren't nested in
+	// another sequence are flush and the others indented by Indent. A
+	// sequence at the top of a document counts as containing the sequences
+	// in its items.
+	SeqIndentStyle string
+	// IndentStyle, if "tab", replaces every Indent spaces of indentation by
+	// a tab, after the other layout options. Indentation that isn't a
+	// multiple of Indent keeps the remaining spaces, and the content of
+	// block scalars keeps its spaces. YAML forbids tabs in indentation, so
+	// the output is meant for tools that accept it, and can't be decoded or
+	// formatted again. By default, or with "space", spaces are kept.
+	IndentStyle string
+	// MinimalDiff keeps the source text of every entry of the top-level
+	// mapping of a document whose keys in the same order, values and anchors
+	// are the same after formatting, so that only the entries whose content
+	// changed, such as a nested mapping out of order, are re-encoded, and the
+	// others are only moved to their sorted position. It is experimental,
+	// and can't be combined with Path, FromJSON or StripComments.
+	MinimalDiff bool
+	// AllowPartial formats the documents decoded before a syntax error
+	// instead of discarding them, and skips documents that fail to format
+	// instead of stopping. Format still returns the errors.
+	AllowPartial bool
+	// ExpandAliases replaces aliases by copies of the nodes they refer to
+	// and resolves merge keys, so that the output has no anchors.
+	ExpandAliases bool
+	// NormalizeFloats writes floats in a canonical form, such as 0.5 for .5
+	// and 1000.0 for 1e3, without changing their values.
+	NormalizeFloats bool
+	// NullStyle, if "null", "tilde" or "empty", writes nulls as null, ~ or
+	// nothing respectively. With "empty", only the values of block mappings
+	// are written as nothing, and other nulls as null. An empty NullStyle
+	// keeps nulls as they are.
+	NullStyle string
+	// NormalizeComments puts exactly one space after the # of comments such as
+	// #foo or #  foo, except for those starting with one of
+	// KeepCommentPrefixes.
+	NormalizeComments bool
+	// KeepCommentPrefixes lists the prefixes, such as #! for shebangs and ##
+	// for decorative blocks, of the comment lines that NormalizeComments
+	// leaves alone.
+	KeepCommentPrefixes []string
+	// StripComments removes all comments, including those of the document
+	// nodes. Directives such as %YAML 1.2 aren't comments and are kept.
+	StripComments bool
+	// KeepHeaderComment keeps the head comment of every document with
+	// StripComments, which holds the comments at the top of a document that
+	// are separated from its first entry by a blank line, such as a license
+	// header.
+	KeepHeaderComment bool
+	// TrimStrings removes spaces around string values, except in block
+	// scalars and in strings that consist of spaces only.
+	TrimStrings bool
+	// ExplicitStart starts the first document with a "---" marker. Without
+	// it, the first document starts with one if it does in the input.
+	ExplicitStart bool
+	// ExplicitEnd ends every document with a "..." marker.
+	ExplicitEnd bool
+	// CheckSemantics makes Format fail instead of writing any output if the
+	// formatted stream doesn't decode to the same data as the input.
+	CheckSemantics bool
+	// Path, if not empty, is the dotted path of the node that is formatted in
+	// each document, such as "spec.template.spec". The rest of a document
+	// keeps its order and styles, and documents aren't sorted. A document
+	// without the path is left unchanged and reported to Warn. Aliases are
+	// expanded in the whole document, since anchors may be outside the path.
+	Path string
+	// Set maps dotted paths to values that the scalars at these paths are set
+	// to before formatting, in the lexical order of the paths. Missing keys
+	// are added, and it is an error for a path to lead to a node that isn't
+	// a scalar. The tag of a value is resolved as for a plain scalar, so
+	// that "3" becomes an integer.
+	Set map[string]string
+	// Warn, if not nil, is called with a message for every document that is
+	// left unchanged because it doesn't have Path, and with ReportIndent.
+	Warn func(message string)
+	// ReportIndent reports every document whose block mappings are mostly
+	// indented by another number of columns than Indent to Warn, before it
+	// is reindented.
+	ReportIndent bool
+	// RejectTabs replaces the error of a syntax error on a line indented with
+	// tabs, which YAML doesn't allow, by one that says so.
+	RejectTabs bool
+	// FromJSON expects the input to be a JSON value and fails otherwise. The
+	// output is in block style with quotes only where needed, regardless of
+	// NormalizeStyles and KeepFlow.
+	FromJSON bool
+	// PruneNull removes mapping entries whose value is null.
+	PruneNull bool
+	// PruneEmpty removes mapping entries whose value is an empty mapping or
+	// sequence.
+	PruneEmpty bool
+}
+
+// DefaultOptions returns the options used by the yamlfmt command when no
+// flags are given.
+func DefaultOptions() Options {
+	return Options{
+		Indent:              2,
+		SortDocuments:       true,
+		SortByNamespace:     true,
+		SortByName:          true,
+		SortKeys:            true,
+		NormalizeStyles:     true,
+		RejectTabs:          true,
+		KeepCommentPrefixes: []string{"#!", "##"},
+	}
+}
+
+// Format reads a YAML stream from r and writes its formatted form to w. The
+// directives before the first document, such as %YAML 1.2, are written
+// before the first formatted document.
+// A document that fails to format, or has a syntax error, is reported by a
+// *FormatError with its position in the input. With AllowPartial, the
+// documents before a syntax error are written before the error is returned,
+// and the other documents are still written when one fails to format, where
+// the errors of all documents are joined by errors.Join.
+// Unless documents are sorted, merged, deduplicated or checked for
+// semantics, or the input is JSON or UTF-16, each document is read, decoded,
+// formatted and written before the next one, so that memory use doesn't grow
+// with the size of the stream. The documents before one that fails to
+// format have then already been written, also without AllowPartial.
+func Format(r io.Reader, w io.Writer, opts Options) error {
+	for pattern, transform := range opts.ValueTransforms {
+		if valueTransforms[transform] == nil {
+			return fmt.Errorf("Unknown value transform %q for %q", transform, pattern)
+		}
+	}
+	for pattern, transform := range opts.KeyTransforms {
+		if valueTransforms[transform] == nil {
+			return fmt.Errorf("Unknown key transform %q for %q", transform, pattern)
+		}
+	}
+
+	if opts.Canonical && opts.Path != "" {
+		return errors.New("Canonical can't be combined with Path")
+	}
+	if opts.Canonical {
+		opts = canonicalOptions(opts)
+	}
+	if !seqIndentStyles[opts.SeqIndentStyle] {
+		return fmt.Errorf("Unknown sequence indent style %q", opts.SeqIndentStyle)
+	}
+	if !indentStyles[opts.IndentStyle] {
+		return fmt.Errorf("Unknown indent style %q", opts.IndentStyle)
+	}
+
+	if opts.MergeSequences != "" && opts.MergeSequences != "replace" && opts.MergeSequences != "append" {
+		return fmt.Errorf("Unknown sequence merge %q", opts.MergeSequences)
+	}
+	if opts.MergeDocuments && opts.CheckSemantics {
+		return errors.New("MergeDocuments can't be combined with CheckSemantics")
+	}
+	if opts.MinimalDiff && (opts.Path != "" || opts.FromJSON || opts.StripComments) {
+		return errors.New("MinimalDiff can't be combined with Path, FromJSON or StripComments")
+	}
+	if opts.IndentStyle == "tab" && (opts.MinimalDiff || opts.CheckSemantics) {
+		return errors.New("IndentStyle tab can't be combined with MinimalDiff or CheckSemantics")
+	}
+
+	if opts.DebugOutput == nil {
+		opts.DebugOutput = os.Stderr
+	}
+
+	if opts.Path != "" {
+		opts.SortDocuments = false
+	}
+	input := bufio.NewReader(r)
+	if streamed(&opts) && !opts.FromJSON {
+		if start, _ := input.Peek(2); !isUTF16(start) {
+			return formatStream(input, w, &opts)
+		}
+	}
+
+	source, err := ioutil.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	if opts.FromJSON {
+		if err := checkJSON(source); err != nil {
+			return err
+		}
+		opts.NormalizeStyles = true
+		opts.KeepFlow = false
+	}
+	if streamed(&opts) && !isUTF16(source) {
+		return formatStream(bytes.NewReader(source), w, &opts)
+	}
+	lines := strings.Split(string(source), "\n")
+
+	d := yaml.NewDecoder(bytes.NewReader(withoutVersion(source)))
+	in := &yaml.Node{}
+	err = decode(d, in)
+	docs := []*yaml.Node{}
+
+	for err == nil {
+		docs = append(docs, in)
+		in = &yaml.Node{}
+		err = decode(d, in)
+	}
+
+	if err == io.EOF {
+		err = nil
+	} else {
+		err = syntaxError(err, len(docs)+1, lines, &opts)
+	}
+	if err != nil && !opts.AllowPartial {
+		return err
+	}
+
+	positions := map[*yaml.Node]int{}
+	for i, doc := range docs {
+		positions[doc] = i + 1
+	}
+	spans := map[*yaml.Node]string{}
+	if opts.MinimalDiff {
+		for i, doc := range docs {
+			sourceSpans(doc, lines, i == 0, spans)
+		}
+	}
+
+	for _, doc := range docs {
+		reportIndent(doc, positions[doc], &opts)
+	}
+	if opts.MergeDocuments {
+		docs = mergeDocuments(docs, opts.MergeSequences == "append")
+	}
+	if opts.SortDocuments && opts.GroupDocuments {
+		groupDocuments(docs, opts)
+	} else if opts.SortDocuments && opts.ReverseDocuments {
+		sort.SliceStable(docs, func(i, j int) bool {
+			return sortDocument(docs[j], docs[i], opts)
+		})
+	} else if opts.SortDocuments {
+		sort.SliceStable(docs, func(i, j int) bool {
+			return sortDocument(docs[i], docs[j], opts)
+		})
+	}
+
+	out := w
+	var buffer bytes.Buffer
+	if opts.CheckSemantics {
+		out = &buffer
+	}
+
+	// Format all documents before writing any, so that nothing is written
+	// when a document fails without AllowPartial.
+	formatted := []*yaml.Node{}
+	failed := []error{}
+	for _, doc := range docs {
+		if e := formatAt(doc, positions[doc], lines, &opts); e != nil {
+			if !opts.AllowPartial {
+				return e
+			}
+			failed = append(failed, e)
+			continue
+		}
+		formatted = append(formatted, doc)
+	}
+	if len(failed) > 0 {
+		err = errors.Join(append(failed, err)...)
+	}
+
+	if opts.DedupDocuments {
+		kept, e := dedupDocuments(formatted, opts.DedupIgnoreComments)
+		if e != nil {
+			return e
+		}
+		formatted = kept
+	}
+
+	for i, doc := range formatted {
+		if e := encodeDocument(out, doc, i == 0, lines, spans, &opts); e != nil {
+			return e
+		}
+	}
+
+	if opts.CheckSemantics {
+		if err == nil {
+			if e := checkSemantics(source, buffer.Bytes(), opts.DedupDocuments); e != nil {
+				return e
+			}
+		}
+		if _, e := io.Copy(w, &buffer); e != nil {
+			return e
+		}
+	}
+	return err
+}
+
+// streamed reports whether Format formats the documents one at a time,
+// since they aren't sorted, merged, deduplicated or checked as a whole.
+func streamed(opts *Options) bool {
+	return !opts.SortDocuments && !opts.MergeDocuments && !opts.DedupDocuments && !opts.CheckSemantics
+}
+
+// reportIndent warns with ReportIndent if the document at the given position
+// in the stream is indented differently from Indent.
+func reportIndent(doc *yaml.Node, position int, opts *Options) {
+	if !opts.ReportIndent || opts.Warn == nil {
+		return
+	}
+	if width := indentWidth(doc); width > 0 && width != opts.Indent {
+		opts.Warn(fmt.Sprintf("document %d: indented by %d spaces instead of %d", position, width, opts.Indent))
+	}
+}
+
+// writeStart writes the directives before the first document of the stream
+// with the given lines, followed by a "---" marker if there are directives,
+// the first document starts with one, ExplicitStart is set, or the text of
+// the first document is empty. Directives have to be followed by an explicit
+// document start, and an empty document without one decodes as no document.
+func writeStart(w io.Writer, lines []string, empty bool, opts *Options) error {
+	directives := leadingDirectives(lines)
+	if len(directives) == 0 && !opts.ExplicitStart && !explicitStart(lines) && !empty {
+		return nil
+	}
+	_, err := io.WriteString(w, strings.Join(append(directives, "---"), "\n")+"\n")
+	return err
+}
+
+// formatAt formats the document at the given position in the stream with
+// formatDocument. It warns about a document without Path instead of failing,
+// and reports other failures by a *FormatError.
+func formatAt(doc *yaml.Node, position int, lines []string, opts *Options) error {
+	err := formatDocument(doc, lines, opts)
+	if err == errNoPath {
+		if opts.Warn != nil {
+			opts.Warn(fmt.Sprintf("document %d: %s not found, left unchanged", position, opts.Path))
+		}
+		return nil
+	}
+	if err != nil {
+		return &FormatError{DocIndex: position, Cause: err}
+	}
+	return nil
+}
+
+// errNoPath is returned by formatDocument for a document without the path
+// given by Options.Path.
+var errNoPath = errors.New("path not found")
+
+// formatDocument rewrites doc, or the node at Path, into its formatted form.
+func formatDocument(doc *yaml.Node, lines []string, opts *Options) error {
+	attachFootComments(doc, lines, nil)
+	path := []string{}
+	if opts.Path != "" {
+		path = strings.Split(opts.Path, ".")
+		if _, err := traverse(doc, path...); err != nil {
+			return errNoPath
+		}
+	}
+
+	if opts.ExpandAliases {
+		if err := expandAliases(doc); err != nil {
+			return err
+		}
+	}
+	if opts.Canonical || opts.StripComments {
+		header := doc.HeadComment
+		stripComments(doc)
+		if opts.KeepHeaderComment {
+			doc.HeadComment = header
+		}
+	}
+	if err := setValues(doc, opts.Set); err != nil {
+		return err
+	}
+	root := queueItem{Node: doc, Path: path}
+	if opts.Path != "" {
+		// Traverse again, as expanding aliases may have copied the node.
+		node, _ := traverse(doc, path...)
+		root = queueItem{Node: node, Path: path, Indent: len(path) + 1}
+	}
+	prune(root.Node, opts)
+	if err := normalize(root, opts); err != nil {
+		return err
+	}
+	orderAnchors(doc)
+	return nil
+}
+
+// decode decodes the next document of d into v. The yaml.v3 parser panics
+// on some malformed input, such as invalid UTF-8 after a tag, which decode
+// returns as an error instead.
+func decode(d *yaml.Decoder, v interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("yaml: cannot parse the input: %v", r)
+		}
+	}()
+	return d.Decode(v)
+}
+
+var syntaxErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// syntaxError returns the syntax error err, found by the decoder in the
+// document at the given position of the stream with the given lines, as a
+// *FormatError, after tabError with RejectTabs.
+func syntaxError(err error, position int, lines []string, opts *Options) error {
+	if opts.RejectTabs {
+		err = tabError(lines, err)
+	}
+	return &FormatError{DocIndex: position, Cause: err, Syntax: true}
+}
+
+// tabError returns an error saying that tabs can't indent YAML if the syntax
+// error err is on a line whose indentation contains a tab, and err otherwise.
+func tabError(lines []string, err error) error {
+	m := syntaxErrorLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(m[1])
+	if line < 1 || line > len(lines) {
+		return err
+	}
+	if !tabIndented(lines[line-1]) {
+		return err
+	}
+	return fmt.Errorf("line %d: %s", line, tabMessage)
+}
+
+const tabMessage = "found a tab in the indentation, indent YAML with spaces instead"
+
+// tabIndented reports whether the indentation of line contains a tab.
+func tabIndented(line string) bool {
+	return strings.Contains(line[:len(line)-len(strings.TrimLeft(line, " \t"))], "\t")
+}
+
+// checkJSON fails if source isn't a single JSON value, naming the line of
+// the syntax error.
+func checkJSON(source []byte) error {
+	var v interface{}
+	err := json.Unmarshal(source, &v)
+	if e, ok := err.(*json.SyntaxError); ok {
+		line := bytes.Count(source[:e.Offset], []byte("\n")) + 1
+		return fmt.Errorf("line %d: invalid JSON: %v", line, e)
+	}
+	return err
+}
+
+// encodeDocument writes doc to w, preceded by a "---" separator unless it
+// is the first document, which is preceded by writeStart for the stream
+// with the given lines instead and keeps the tag handles of its directives,
+// and followed by a "..." marker with ExplicitEnd. With MinimalDiff, the
+// entries of doc keep their source text from spans where they are
+// unchanged.
+func encodeDocument(w io.Writer, doc *yaml.Node, first bool, lines []string, spans map[*yaml.Node]string, opts *Options) error {
+	text, minimal := "", false
+	var err error
+	if opts.MinimalDiff {
+		if text, minimal, err = encodeMinimal(doc, spans, opts); err != nil {
+			return err
+		}
+	}
+	if !minimal {
+		if text, err = encodeNode(doc, opts); err != nil {
+			return err
+		}
+	}
+	if first {
+		text = withTagHandles(text, leadingDirectives(lines))
+		if err := writeStart(w, lines, isEmptyText(text), opts); err != nil {
+			return err
+		}
+	} else if _, err := io.WriteString(w, "---\n"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, text); err != nil {
+		return err
+	}
+	if opts.ExplicitEnd {
+		if _, err := io.WriteString(w, "...\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeNode returns the text of node as written by the encoder with the
+// layout of opts. A panic of the encoder is returned as an error.
+func encodeNode(node *yaml.Node, opts *Options) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot encode the document: %v", r)
+		}
+	}()
+	defer untagMergeKeys(node)()
+	if opts.NullStyle == "empty" {
+		defer emptyNulls(node)()
+	}
+	var buffer bytes.Buffer
+	e := yaml.NewEncoder(&buffer)
+	e.SetIndent(opts.Indent)
+	if err := e.Encode(node); err != nil {
+		return "", err
+	}
+	if err := e.Close(); err != nil {
+		return "", err
+	}
+	text = trimEmptyValues(buffer.String())
+	text = indentSequences(text, opts.Indent, opts.SeqIndentStyle)
+	if opts.IndentStyle == "tab" {
+		text = indentTabs(text, opts.Indent)
+	}
+	return text, nil
+}
+
+// groupDocuments sorts docs by the position at which their kind first
+// appears, and documents of the same kind with sortDocument. Documents
+// without a kind form a group of their own.
+func groupDocuments(docs []*yaml.Node, opts Options) {
+	ranks := map[string]int{}
+	rank := func(doc *yaml.Node) int {
+		// Prefix kinds so that a missing kind differs from an empty one.
+		kind := ""
+		if node, err := traverse(doc, "kind"); err == nil {
+			kind = "!" + node.Value
+		}
+		if _, ok := ranks[kind]; !ok {
+			ranks[kind] = len(ranks)
+		}
+		return ranks[kind]
+	}
+	for _, doc := range docs {
+		rank(doc)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		rank_i, rank_j := rank(docs[i]), rank(docs[j])
+		if rank_i != rank_j {
+			return rank_i < rank_j
+		}
+		return sortDocument(docs[i], docs[j], opts)
+	})
+}
+
+// sortDocument reports whether document i sorts before document j by kind,
+// by namespace and name as far as opts.SortByNamespace and opts.SortByName
+// say, and then by opts.SortAnnotation.
+func sortDocument(i *yaml.Node, j *yaml.Node, opts Options) bool {
+	kind_i, err_kind_i := traverse(i, "kind")
+	kind_j, err_kind_j := traverse(j, "kind")
+	if err_kind_i != nil && err_kind_j == nil {
+		return false
+	} else if err_kind_j != nil && err_kind_i == nil {
+		return true
+	} else if err_kind_i == nil && err_kind_j == nil && kind_i.Value != kind_j.Value {
+		return kind_i.Value < kind_j.Value
+	}
+
+	if opts.SortByNamespace {
+		if less, ok := compareField(i, j, "metadata", "namespace"); ok {
+			return less
+		}
+	}
+	if opts.SortByName {
+		if less, ok := compareField(i, j, "metadata", "name"); ok {
+			return less
+		}
+	}
+	if opts.SortAnnotation != "" {
+		index_i, ok_i := annotationNumber(i, opts.SortAnnotation)
+		index_j, ok_j := annotationNumber(j, opts.SortAnnotation)
+		if ok_i != ok_j {
+			return ok_i
+		}
+		return ok_i && index_i < index_j
+	}
+	return false
+}
+
+// annotationNumber returns the number that the annotation of doc holds, and
+// false if doc has no such annotation or it isn't a number.
+func annotationNumber(doc *yaml.Node, annotation string) (float64, bool) {
+	node, err := traverse(doc, "metadata", "annotations", annotation)
+	if err != nil || node.Kind&yaml.ScalarNode == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(node.Value), 64)
+	return n, err == nil
+}
+
+// compareField compares documents i and j by the scalar at path, where a
+// document with the field sorts before one without it. It returns false for
+// ok if the documents don't differ at path.
+func compareField(i *yaml.Node, j *yaml.Node, path ...string) (less bool, ok bool) {
+	field_i, err_i := traverse(i, path...)
+	field_j, err_j := traverse(j, path...)
+	if err_i != nil && err_j == nil {
+		return false, true
+	} else if err_j != nil && err_i == nil {
+		return true, true
+	} else if err_i == nil && err_j == nil && field_i.Value != field_j.Value {
+		return field_i.Value < field_j.Value, true
+	}
+	return false, false
+}
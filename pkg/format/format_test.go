@@ -0,0 +1,2046 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFormat(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(input), &out, DefaultOptions()))
+	assert.Equal(t, output, out.String())
+}
+
+func TestFormatCustomOptions(t *testing.T) {
+	opts := Options{Indent: 4}
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(input), &out, opts))
+	assert.Equal(t, `apiVersion: apps/v1 # for versions before 1.9.0 use apps/v1beta2
+spec:
+    selector:
+        app: sqlflow-mysql
+---
+apiVersion: v1
+metadata:
+    name: sqlflow-mysql
+`, out.String())
+}
+
+func TestFormatKeepsTypedQuotes(t *testing.T) {
+	in := `
+int: "123"
+bool: "yes"
+none: "null"
+float: "1.0"
+str: "foo"
+`
+	expected := `bool: "yes"
+float: "1.0"
+int: "123"
+none: "null"
+str: foo
+`
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatKeepsIntegerNotation(t *testing.T) {
+	in := `
+mode: 0o755
+legacy: 0755
+hex: 0xdeadbeef
+big: 1_000_000
+tagged: !!int 0o644
+`
+	expected := `big: 1_000_000
+hex: 0xdeadbeef
+legacy: 0755
+mode: 0o755
+tagged: 0o644
+`
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatSortsByMergedMetadata(t *testing.T) {
+	in := `
+kind: Service
+metadata:
+  name: b
+---
+common: &common
+  name: a
+kind: Service
+metadata:
+  <<: *common
+`
+	expected := `common: &common
+  name: a
+kind: Service
+metadata:
+  <<: *common
+---
+kind: Service
+metadata:
+  name: b
+`
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatReverse(t *testing.T) {
+	in := `kind: A
+metadata:
+  name: x
+---
+kind: C
+c: 1
+a: 2
+b: 3
+---
+kind: B
+`
+	expected := `kind: C
+c: 1
+b: 3
+a: 2
+---
+kind: B
+---
+kind: A
+metadata:
+  name: x
+`
+	opts := DefaultOptions()
+	opts.ReverseDocuments = true
+	opts.DescendingKeys = true
+	opts.KeyOrder = map[string][]string{"": {"kind"}}
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatGroupDocuments(t *testing.T) {
+	in := `kind: Service
+metadata:
+  name: web
+---
+kind: Deployment
+metadata:
+  name: web
+---
+kind: Service
+metadata:
+  name: api
+---
+kind: Deployment
+metadata:
+  name: api
+`
+	expected := `kind: Service
+metadata:
+  name: api
+---
+kind: Service
+metadata:
+  name: web
+---
+kind: Deployment
+metadata:
+  name: api
+---
+kind: Deployment
+metadata:
+  name: web
+`
+	opts := DefaultOptions()
+	opts.GroupDocuments = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatSortFields(t *testing.T) {
+	in := `kind: Service
+metadata:
+  name: web
+  namespace: prod
+---
+kind: ConfigMap
+metadata:
+  name: b
+---
+kind: Service
+metadata:
+  name: api
+  namespace: dev
+---
+kind: Service
+metadata:
+  name: api
+  namespace: prod
+`
+	for _, c := range []struct {
+		namespace, name bool
+		expected        []string
+	}{
+		{true, true, []string{"b", "dev/api", "prod/api", "prod/web"}},
+		{false, true, []string{"b", "dev/api", "prod/api", "prod/web"}},
+		{true, false, []string{"b", "dev/api", "prod/web", "prod/api"}},
+		{false, false, []string{"b", "prod/web", "dev/api", "prod/api"}},
+	} {
+		opts := DefaultOptions()
+		opts.SortByNamespace = c.namespace
+		opts.SortByName = c.name
+		var out bytes.Buffer
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+		names := []string{}
+		for _, doc := range strings.Split(out.String(), "---\n") {
+			var m struct {
+				Metadata struct{ Name, Namespace string }
+			}
+			assert.NoError(t, yaml.Unmarshal([]byte(doc), &m))
+			name := m.Metadata.Name
+			if m.Metadata.Namespace != "" {
+				name = m.Metadata.Namespace + "/" + name
+			}
+			names = append(names, name)
+		}
+		assert.Equal(t, c.expected, names, "namespace %v, name %v", c.namespace, c.name)
+	}
+}
+
+func TestFormatSortAnnotation(t *testing.T) {
+	in := `kind: ConfigMap
+metadata:
+  annotations:
+    config.kubernetes.io/index: "10"
+  name: c
+---
+kind: ConfigMap
+metadata:
+  name: none
+---
+kind: ConfigMap
+metadata:
+  annotations:
+    config.kubernetes.io/index: "2"
+  name: c
+---
+kind: ConfigMap
+metadata:
+  annotations:
+    config.kubernetes.io/index: "1"
+---
+kind: ConfigMap
+metadata:
+  annotations:
+    config.kubernetes.io/index: "9"
+`
+	opts := DefaultOptions()
+	opts.SortAnnotation = "config.kubernetes.io/index"
+	opts.SortByName = false
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	indexes := []string{}
+	for _, doc := range strings.Split(out.String(), "---\n") {
+		var m struct {
+			Metadata struct{ Annotations map[string]string }
+		}
+		assert.NoError(t, yaml.Unmarshal([]byte(doc), &m))
+		indexes = append(indexes, m.Metadata.Annotations["config.kubernetes.io/index"])
+	}
+	assert.Equal(t, []string{"1", "2", "9", "10", ""}, indexes)
+}
+
+func TestFormatNormalizeFloats(t *testing.T) {
+	in := `
+a: 1.
+b: .5
+c: 1e3
+d: "1.0"
+e: -.inf
+f: .NaN
+g: 1.5e-7
+h: !!float 2
+i: 1
+`
+	expected := `a: 1.0
+b: 0.5
+c: 1000.0
+d: "1.0"
+e: -.inf
+f: .NaN
+g: 1.5e-07
+h: !!float 2.0
+i: 1
+`
+	opts := DefaultOptions()
+	opts.NormalizeFloats = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatNullStyle(t *testing.T) {
+	in := `
+a:
+b: ~
+c: Null
+d: "null"
+e: [~, null]
+`
+	for style, expected := range map[string]string{
+		"":      "a:\nb: ~\nc: Null\nd: \"null\"\ne:\n- ~\n- null\n",
+		"null":  "a: null\nb: null\nc: null\nd: \"null\"\ne:\n- null\n- null\n",
+		"tilde": "a: ~\nb: ~\nc: ~\nd: \"null\"\ne:\n- ~\n- ~\n",
+		"empty": "a:\nb:\nc:\nd: \"null\"\ne:\n- null\n- null\n",
+	} {
+		opts := DefaultOptions()
+		opts.NullStyle = style
+		var out bytes.Buffer
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+		assert.Equal(t, expected, out.String(), style)
+	}
+
+	// Nulls in flow collections stay null, which an empty item wouldn't.
+	in = "a: [1, null]\nb: {x: null, y: {z: ~}}\nc: null\n"
+	for _, set := range []func(*Options){
+		func(opts *Options) { opts.KeepFlow = true },
+		func(opts *Options) { opts.SeqStyle = "flow" },
+	} {
+		opts := DefaultOptions()
+		opts.NullStyle = "empty"
+		opts.CheckSemantics = true
+		set(&opts)
+		var out bytes.Buffer
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+		assert.Contains(t, out.String(), "a: [1, null]\n")
+		assert.Contains(t, out.String(), "c:\n")
+	}
+	opts := DefaultOptions()
+	opts.NullStyle = "empty"
+	opts.KeepFlow = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, "a: [1, null]\nb: {x: null, y: {z: null}}\nc:\n", out.String())
+}
+
+func TestFormatKeyOrder(t *testing.T) {
+	in := `
+spec:
+  containers:
+  - image: nginx
+    name: web
+    args: []
+  - ports: []
+    name: sidecar
+metadata:
+  name: app
+  annotations: {}
+`
+	expected := `metadata:
+  annotations: {}
+  name: app
+spec:
+  containers:
+  - name: web
+    args: []
+    image: nginx
+  - name: sidecar
+    ports: []
+`
+	var out bytes.Buffer
+	opts := DefaultOptions()
+	opts.KeyOrder = map[string][]string{"spec.containers.*": {"name"}}
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatKeepFlow(t *testing.T) {
+	in := `
+list: [b, a]
+long: [1, 2, 3]
+map: {y: 1, x: 2}
+`
+	var out bytes.Buffer
+	opts := DefaultOptions()
+	opts.KeepFlow = true
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `list: [b, a]
+long: [1, 2, 3]
+map: {x: 2, y: 1}
+`, out.String())
+
+	out.Reset()
+	opts = DefaultOptions()
+	opts.KeepFlow = true
+	opts.KeepFlowMax = 2
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `list: [b, a]
+long:
+- 1
+- 2
+- 3
+map: {x: 2, y: 1}
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, `list:
+- b
+- a
+long:
+- 1
+- 2
+- 3
+map:
+  x: 2
+  y: 1
+`, out.String())
+}
+
+func TestFormatFlowFoldThreshold(t *testing.T) {
+	in := `
+short: [b, a]
+long: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]
+map: {y: 1, x: 2}
+`
+	expected := `long:
+- 1
+- 2
+- 3
+- 4
+- 5
+- 6
+- 7
+- 8
+- 9
+- 10
+map:
+  x: 2
+  y: 1
+short: [b, a]
+`
+	opts := DefaultOptions()
+	opts.FlowFoldThreshold = 5
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+
+	opts.NormalizeStyles = false
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Contains(t, out.String(), "long:\n- 1\n")
+	assert.Contains(t, out.String(), "map: {x: 2, y: 1}\n")
+}
+
+func TestFormatSortDepth(t *testing.T) {
+	in := `
+b:
+  y: 1
+  x: 2
+a:
+  d: 3
+  c: 4
+`
+	expected := `a:
+  d: 3
+  c: 4
+b:
+  y: 1
+  x: 2
+`
+	opts := DefaultOptions()
+	opts.SortDepth = 1
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatSeqStyle(t *testing.T) {
+	block := `ports:
+- 80
+- 443
+`
+	flow := `ports: [80, 443]
+`
+	opts := DefaultOptions()
+	opts.SeqStyle = "flow"
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(block), &out, opts))
+	assert.Equal(t, flow, out.String())
+
+	opts.SeqStyle = "block"
+	opts.KeepFlow = true
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(flow), &out, opts))
+	assert.Equal(t, block, out.String())
+}
+
+func TestFormatPath(t *testing.T) {
+	in := `kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: "nginx"
+      restartPolicy: Always
+  replicas: 1
+---
+kind: Service
+spec: {z: 1, "y": 2}
+`
+	expected := `kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: nginx
+        name: web
+      restartPolicy: Always
+  replicas: 1
+---
+kind: Service
+spec: {z: 1, "y": 2}
+`
+	warnings := []string{}
+	opts := DefaultOptions()
+	opts.Path = "spec.template.spec"
+	opts.Warn = func(message string) { warnings = append(warnings, message) }
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+	assert.Equal(t, []string{"document 2: spec.template.spec not found, left unchanged"}, warnings)
+
+	opts.Path = "spec.template.spec.containers.0"
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatSortPaths(t *testing.T) {
+	in := `kind: Deployment
+metadata:
+  name: web
+  labels:
+    tier: frontend
+    app: web
+spec:
+  template:
+    metadata:
+      annotations:
+        prometheus.io/scrape: "true"
+        prometheus.io/port: "8080"
+    spec:
+      replicas: 2
+`
+	expected := `kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+    tier: frontend
+spec:
+  template:
+    metadata:
+      annotations:
+        prometheus.io/port: "8080"
+        prometheus.io/scrape: "true"
+    spec:
+      replicas: 2
+`
+	opts := DefaultOptions()
+	opts.SortPaths = []string{"**.labels", "**.annotations"}
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatBlockScalarIndent(t *testing.T) {
+	in := `
+steps:
+  script: |
+    if true; then
+      echo hi
+    fi
+  lead: |2
+      indented
+    base
+  folded: >
+    one
+      more indented
+    two
+`
+	expected := `steps:
+    folded: |
+        one
+          more indented
+        two
+    lead: |4
+          indented
+        base
+    script: |
+        if true; then
+          echo hi
+        fi
+`
+	opts := DefaultOptions()
+	opts.Indent = 4
+	opts.CheckSemantics = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatPreservesTags(t *testing.T) {
+	in := `
+secret: !vault ENC[abc]
+version: !!str 123
+replicas: !!int 3
+custom: !custom
+  y: 2
+  x: 1
+set: !!set
+  a: null
+`
+	expected := `custom: !custom
+  x: 1
+  y: 2
+replicas: 3
+secret: !vault ENC[abc]
+set: !!set
+  a: null
+version: !!str 123
+`
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, expected, out.String())
+
+	assert.EqualError(t, Format(strings.NewReader("a: 1\nb: !%21 2\n"), &out, DefaultOptions()),
+		"document 1: line 2: cannot write the tag !!")
+}
+
+func TestFormatEmptyCollections(t *testing.T) {
+	in := "c: null\nb: []\na: {}\nd: !!map {}\n"
+	for _, set := range []func(*Options){
+		func(o *Options) {},
+		func(o *Options) { o.NormalizeStyles = false },
+		func(o *Options) { o.KeepFlow = true },
+		func(o *Options) { o.SeqStyle = "block" },
+		func(o *Options) { o.ExpandAliases = true },
+	} {
+		opts := DefaultOptions()
+		set(&opts)
+		var out bytes.Buffer
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+		assert.Equal(t, "a: {}\nb: []\nc: null\nd: {}\n", out.String())
+	}
+
+	opts := DefaultOptions()
+	opts.CheckSemantics = true
+	opts.NullStyle = "empty"
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, "a: {}\nb: []\nc:\nd: {}\n", out.String())
+}
+
+func TestFormatSeqIndentStyle(t *testing.T) {
+	in := `
+containers:
+- name: web
+  ports:
+  - 80
+  script: |
+    key:
+    - text
+hosts: [a]
+`
+	for style, expected := range map[string]string{
+		"flush": `containers:
+- name: web
+  ports:
+  - 80
+  script: |
+    key:
+    - text
+hosts:
+- a
+`,
+		"indent": `containers:
+  - name: web
+    ports:
+      - 80
+    script: |
+      key:
+      - text
+hosts:
+  - a
+`,
+		"nested": `containers:
+- name: web
+  ports:
+    - 80
+  script: |
+    key:
+    - text
+hosts:
+- a
+`,
+	} {
+		opts := DefaultOptions()
+		opts.SeqIndentStyle = style
+		opts.CheckSemantics = true
+		var out bytes.Buffer
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts), style)
+		assert.Equal(t, expected, out.String(), style)
+	}
+
+	opts := DefaultOptions()
+	opts.Indent = 4
+	opts.SeqIndentStyle = "flush"
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `containers:
+- name: web
+  ports:
+  - 80
+  script: |
+    key:
+    - text
+hosts:
+- a
+`, out.String())
+
+	opts.SeqIndentStyle = "deep"
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts), `Unknown sequence indent style "deep"`)
+}
+
+func TestFormatIndentStyle(t *testing.T) {
+	in := `
+spec:
+  containers:
+  - name: web
+    args:
+    - -v
+    script: |
+      if true; then
+        make
+      fi
+`
+	opts := DefaultOptions()
+	opts.Indent = 4
+	opts.IndentStyle = "tab"
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, "spec:\n"+
+		"\tcontainers:\n"+
+		"\t  - args:\n"+
+		"\t\t  - -v\n"+
+		"\t\tname: web\n"+
+		"\t\tscript: |\n"+
+		"          if true; then\n"+
+		"            make\n"+
+		"          fi\n", out.String())
+
+	opts.IndentStyle = "space"
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.NotContains(t, out.String(), "\t")
+
+	opts.IndentStyle = "tab"
+	opts.CheckSemantics = true
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts), "IndentStyle tab can't be combined with MinimalDiff or CheckSemantics")
+
+	opts.IndentStyle = "tabs"
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts), `Unknown indent style "tabs"`)
+}
+
+func TestFormatReportIndent(t *testing.T) {
+	in := `
+metadata:
+    labels:
+        app: web
+    name: web
+spec:
+    ports: [80]
+---
+metadata:
+  name: db
+`
+	warnings := []string{}
+	opts := DefaultOptions()
+	opts.SortDocuments = false
+	opts.ReportIndent = true
+	opts.Warn = func(message string) { warnings = append(warnings, message) }
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, []string{"document 1: indented by 4 spaces instead of 2"}, warnings)
+
+	warnings = []string{}
+	opts.Indent = 4
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, []string{"document 2: indented by 2 spaces instead of 4"}, warnings)
+}
+
+func TestFormatLongScalars(t *testing.T) {
+	long := strings.Repeat("word ", 99) + "end!"
+	in := "a: " + long + "\nb: '" + long + " #y'\nc: |\n  " + long + "\n"
+
+	opts := DefaultOptions()
+	opts.CheckSemantics = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, in, out.String())
+}
+
+func TestFormatPrune(t *testing.T) {
+	in := `
+metadata:
+  annotations: null
+  labels: {}
+  name: app
+spec:
+  template:
+    metadata:
+      labels:
+        app: null
+  ports: []
+`
+	var out bytes.Buffer
+	opts := DefaultOptions()
+	opts.PruneNull = true
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `metadata:
+  labels: {}
+  name: app
+spec:
+  ports: []
+  template:
+    metadata:
+      labels: {}
+`, out.String())
+
+	out.Reset()
+	opts = DefaultOptions()
+	opts.PruneEmpty = true
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `metadata:
+  annotations: null
+  name: app
+spec:
+  template:
+    metadata:
+      labels:
+        app: null
+`, out.String())
+
+	out.Reset()
+	opts = DefaultOptions()
+	opts.PruneNull = true
+	opts.PruneEmpty = true
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `metadata:
+  name: app
+`, out.String())
+}
+
+const (
+	input = `
+apiVersion: apps/v1 # for versions before 1.9.0 use apps/v1beta2
+spec:
+  selector:
+         app:    sqlflow-mysql
+---
+apiVersion: v1   
+metadata:
+    name: sqlflow-mysql
+`
+	output = `apiVersion: v1
+metadata:
+  name: sqlflow-mysql
+---
+apiVersion: apps/v1 # for versions before 1.9.0 use apps/v1beta2
+spec:
+  selector:
+    app: sqlflow-mysql
+`
+)
+
+func TestFormatExpandAliases(t *testing.T) {
+	in := `
+base: &base
+  image: nginx
+  ports: &ports [80]
+web:
+  <<: *base
+  name: web
+  image: nginx:1.19
+sidecar:
+  <<: [*base, {name: sidecar, image: envoy}]
+  extra: *ports
+`
+	expected := `base:
+  image: nginx
+  ports:
+  - 80
+sidecar:
+  extra:
+  - 80
+  image: nginx
+  name: sidecar
+  ports:
+  - 80
+web:
+  image: nginx:1.19
+  name: web
+  ports:
+  - 80
+`
+	opts := DefaultOptions()
+	opts.ExpandAliases = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatAnchorOrder(t *testing.T) {
+	in := `
+web:
+  resources: &resources
+    memory: 1Gi
+    cpu: 1
+api:
+  <<: &defaults
+    replicas: 2
+  resources: *resources
+worker: *defaults
+batch: *resources # Shared.
+`
+	expected := `api:
+  <<: &defaults
+    replicas: 2
+  resources: &resources
+    cpu: 1
+    memory: 1Gi
+batch: *resources # Shared.
+web:
+  resources: *resources
+worker: *defaults
+`
+	opts := DefaultOptions()
+	opts.CheckSemantics = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+	var v interface{}
+	assert.NoError(t, yaml.Unmarshal(out.Bytes(), &v))
+}
+
+func TestFormatMinimalDiff(t *testing.T) {
+	in := `# The web server.
+apiVersion:   apps/v1
+kind: "Deployment"
+metadata: {name: web,   namespace: prod}
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx
+  replicas: 3 # Enough.
+---
+b: 1
+a:
+    - "x"
+`
+	expected := `# The web server.
+apiVersion:   apps/v1
+kind: "Deployment"
+metadata: {name: web,   namespace: prod}
+spec:
+  replicas: 3 # Enough.
+  template:
+    spec:
+      containers:
+      - image: nginx
+        name: web
+---
+a:
+    - "x"
+b: 1
+`
+	opts := DefaultOptions()
+	opts.MinimalDiff = true
+	opts.CheckSemantics = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+
+	opts.Path = "spec"
+	assert.Error(t, Format(strings.NewReader(in), &out, opts))
+}
+
+func TestFormatSortLabelsByName(t *testing.T) {
+	in := `metadata:
+  labels:
+    tier: web
+    name: web
+    example.com/Name: web
+    app.kubernetes.io/name: web
+    app.kubernetes.io/component: server
+  annotations:
+    b/x: 1
+    a/x: 2
+spec:
+  names:
+    z/name: 1
+    name: 2
+`
+	expected := `metadata:
+  annotations:
+    a/x: 2
+    b/x: 1
+  labels:
+    app.kubernetes.io/component: server
+    name: web
+    app.kubernetes.io/name: web
+    example.com/Name: web
+    tier: web
+spec:
+  names:
+    name: 2
+    z/name: 1
+`
+	opts := DefaultOptions()
+	opts.SortLabelsByName = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatKeepRegions(t *testing.T) {
+	in := `z: 1
+# region web
+port: 80
+# About the host.
+host: web
+# endregion
+# region db
+user: admin
+name: db
+# endregion
+a: 1
+nested:
+  y: 1
+  # region inner
+  x: 1
+  w: 1
+  # endregion
+`
+	// The encoder writes blank lines before head comments.
+	expected := `z: 1
+# region web
+# About the host.
+host: web
+port: 80
+# endregion
+# region db
+name: db
+user: admin
+# endregion
+a: 1
+nested:
+  y: 1
+  # region inner
+  w: 1
+  x: 1
+  # endregion
+`
+	opts := DefaultOptions()
+	opts.KeepRegions = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	lines := []string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, expected, strings.Join(lines, "\n")+"\n")
+}
+
+func TestFormatAllowPartial(t *testing.T) {
+	in := `
+b: 1
+a: 2
+---
+c: [
+`
+	var out bytes.Buffer
+	assert.Error(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, "", out.String())
+
+	opts := DefaultOptions()
+	opts.AllowPartial = true
+	out.Reset()
+	assert.Error(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, "a: 2\nb: 1\n", out.String())
+}
+
+func TestFormatRejectTabs(t *testing.T) {
+	in := "a:\n  b: |\n    text\n    \tcontent\n  c:\n\t- d\n"
+	var out bytes.Buffer
+	assert.EqualError(t, Format(strings.NewReader(in), &out, DefaultOptions()),
+		"document 1: line 6: found a tab in the indentation, indent YAML with spaces instead")
+
+	opts := DefaultOptions()
+	opts.RejectTabs = false
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts),
+		"document 1: yaml: line 6: found character that cannot start any token")
+}
+
+func TestFormatDocumentErrors(t *testing.T) {
+	in := `kind: A
+---
+kind: B
+loop: &x [*x]
+---
+kind: C
+`
+	opts := DefaultOptions()
+	opts.ExpandAliases = true
+	var out bytes.Buffer
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts),
+		"document 2: line 4: alias *x refers to a node that contains it")
+	assert.Equal(t, "", out.String())
+
+	opts.AllowPartial = true
+	out.Reset()
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts),
+		"document 2: line 4: alias *x refers to a node that contains it")
+	assert.Equal(t, "kind: A\n---\nkind: C\n", out.String())
+}
+
+func TestFormatFootComments(t *testing.T) {
+	in := `c: 1
+# foot of c
+
+b:
+  z: 1
+  # foot of z
+
+  y: 2
+# foot of b
+
+a: 3
+# foot of a
+`
+	expected := `a: 3
+# foot of a
+
+b:
+  y: 2
+  z: 1
+  # foot of z
+# foot of b
+
+c: 1
+# foot of c
+`
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, expected, out.String())
+
+	var again bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(out.String()), &again, DefaultOptions()))
+	assert.Equal(t, expected, again.String())
+}
+
+func TestFormatNormalizeComments(t *testing.T) {
+	in := `#!/usr/bin/env yaml
+#head
+a: 1 #line
+#  foo
+
+####
+## section
+b:
+  #  indented
+  c: 2
+`
+	expected := `#!/usr/bin/env yaml
+# head
+a: 1 # line
+# foo
+
+####
+## section
+b:
+  # indented
+  c: 2
+`
+	opts := DefaultOptions()
+	opts.NormalizeComments = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+
+	opts.KeepCommentPrefixes = []string{"#!", "#  "}
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `#!/usr/bin/env yaml
+# head
+a: 1 # line
+#  foo
+
+# ###
+# # section
+b:
+  #  indented
+  c: 2
+`, out.String())
+
+	opts.KeepCommentPrefixes = nil
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Contains(t, out.String(), "# !/usr/bin/env yaml\n")
+}
+
+func TestFormatMultibyteComments(t *testing.T) {
+	in := `b: 1 # café
+# 注释
+a: 2 # cafè
+`
+	for _, sortDocuments := range []bool{true, false} {
+		opts := DefaultOptions()
+		opts.SortDocuments = sortDocuments
+		var out bytes.Buffer
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+		assert.Equal(t, `# 注释
+a: 2 # cafè
+b: 1 # café
+`, out.String())
+	}
+}
+
+func TestFormatStripComments(t *testing.T) {
+	in := `%YAML 1.2
+# License.
+
+# About the service.
+kind: Service # The kind.
+metadata:
+  # Name.
+  name: web
+  labels: # None yet.
+    # The tier.
+    tier: web # Web.
+  # End of metadata.
+spec:
+  ports:
+  # HTTP.
+  - 80 # Plain.
+  - 443
+  # HTTPS.
+data: |
+  # Not a comment.
+# Trailing.
+---
+# Second.
+a: [1, 2] # Flow.
+# End.
+`
+	expected := `%YAML 1.2
+---
+data: |
+  # Not a comment.
+kind: Service
+metadata:
+  labels:
+    tier: web
+  name: web
+spec:
+  ports:
+  - 80
+  - 443
+---
+a:
+- 1
+- 2
+`
+	opts := DefaultOptions()
+	opts.StripComments = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+
+	opts.KeepHeaderComment = true
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader("# License.\n\n# About b.\nb: 1 # One.\na: 2\n"), &out, opts))
+	assert.Equal(t, "# License.\n\na: 2\nb: 1\n", out.String())
+}
+
+func TestFormatTrimStrings(t *testing.T) {
+	in := `
+padded: "  foo  "
+spaces: "   "
+number: " 1 "
+block: |
+  text
+    indented
+`
+	expected := `block: |
+  text
+    indented
+number: "1"
+padded: foo
+spaces: '   '
+`
+	opts := DefaultOptions()
+	opts.TrimStrings = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatAllowedKeys(t *testing.T) {
+	in := `
+kind: Service
+status:
+  loadBalancer: {}
+metadata:
+  name: web
+  uid: 1234
+spec:
+  status: kept
+`
+	expected := `kind: Service
+metadata:
+  name: web
+spec:
+  status: kept
+`
+	var trace bytes.Buffer
+	opts := DefaultOptions()
+	opts.AllowedKeys = map[string][]string{"": {"kind", "metadata", "spec"}, "metadata": {"name"}}
+	opts.Debug = true
+	opts.DebugOutput = &trace
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+	assert.Contains(t, trace.String(), "Dropped key .status\n")
+	assert.Contains(t, trace.String(), "Dropped key .metadata.uid\n")
+}
+
+func TestFormatValueTransforms(t *testing.T) {
+	in := `
+metadata:
+  labels:
+    env: Production
+    team: Platform
+  name: Web
+`
+	expected := `metadata:
+  labels:
+    env: production
+    team: Platform
+  name: Web
+`
+	opts := DefaultOptions()
+	opts.ValueTransforms = map[string]string{"metadata.labels.env": "lower"}
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+
+	opts.ValueTransforms = map[string]string{"metadata.labels.env": "title"}
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts),
+		`Unknown value transform "title" for "metadata.labels.env"`)
+}
+
+func TestFormatValueTransformsKeepKeys(t *testing.T) {
+	in := `
+MyKey: MixedValue
+Nested:
+  InnerKey: Value
+  " Padded ": "  Spaced  "
+`
+	expected := `MyKey: mixedvalue
+Nested:
+  " Padded ": spaced
+  InnerKey: value
+`
+	// The keys are at the paths of their mappings, which match too.
+	opts := DefaultOptions()
+	opts.ValueTransforms = map[string]string{"": "lower", "*": "lower", "*.*": "lower"}
+	opts.TrimStrings = true
+	opts.NormalizeComments = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatSortSequences(t *testing.T) {
+	in := `
+spec:
+  containers:
+  - name: web
+    args: [--b, --a]
+    env:
+    - name: PORT
+      value: "80"
+    - value: x
+    - name: HOST
+      value: example.com
+`
+	expected := `spec:
+  containers:
+  - args:
+    - --b
+    - --a
+    env:
+    - name: HOST
+      value: example.com
+    - name: PORT
+      value: "80"
+    - value: x
+    name: web
+`
+	opts := DefaultOptions()
+	opts.SortSequences = map[string]string{"spec.containers.*.env": "name"}
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatPreserveQuotes(t *testing.T) {
+	in := `
+spec:
+  template:
+    spec:
+      containers:
+      - image: "nginx:1.19"
+        name: "web"
+`
+	expected := `spec:
+  template:
+    spec:
+      containers:
+      - image: "nginx:1.19"
+        name: web
+`
+	opts := DefaultOptions()
+	opts.PreserveQuotes = []string{"spec.template.spec.containers.*.image"}
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatQuotedKeys(t *testing.T) {
+	in := `
+"foo-bar": 1
+":weird": 2
+"- dash": 3
+"a: b": 4
+'x #y': 5
+"trailing:": 6
+`
+	expected := `"- dash": 3
+":weird": 2
+"a: b": 4
+foo-bar: 1
+"trailing:": 6
+'x #y': 5
+`
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatDuplicateKeys(t *testing.T) {
+	in := `
+metadata:
+  labels:
+    env: prod
+    "env": dev
+`
+	var out bytes.Buffer
+	assert.EqualError(t, Format(strings.NewReader(in), &out, DefaultOptions()),
+		`document 1: line 5: key "env" collides with key env on line 4`)
+	assert.Equal(t, "", out.String())
+}
+
+func TestFormatKeyTransforms(t *testing.T) {
+	in := `
+metadata:
+  labels:
+    Team: web
+    Env: prod
+`
+	expected := `metadata:
+  labels:
+    env: prod
+    team: web
+`
+	opts := DefaultOptions()
+	opts.KeyTransforms = map[string]string{"metadata.labels": "lower"}
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+
+	in = `
+metadata:
+  labels:
+    Env: prod
+    env: dev
+`
+	out.Reset()
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts),
+		`document 1: line 5: key env collides with key Env on line 4 once transformed by "lower"`)
+	assert.Equal(t, "", out.String())
+
+	opts.KeyTransforms = map[string]string{"metadata.labels": "title"}
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts),
+		`Unknown key transform "title" for "metadata.labels"`)
+}
+
+func TestFormatError(t *testing.T) {
+	in := "a: 1\n---\nb: 1\nb: 2\n"
+	err := Format(strings.NewReader(in), ioutil.Discard, DefaultOptions())
+	var formatErr *FormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.Equal(t, 2, formatErr.DocIndex)
+	assert.EqualError(t, formatErr.Cause, `line 4: key b collides with key b on line 3`)
+	assert.EqualError(t, err, `document 2: line 4: key b collides with key b on line 3`)
+
+	formatErr.File = "config.yaml"
+	assert.EqualError(t, err, `config.yaml: document 2: line 4: key b collides with key b on line 3`)
+
+	// A syntax error is reported for the document that it is in.
+	for _, sorted := range []bool{true, false} {
+		opts := DefaultOptions()
+		opts.SortDocuments = sorted
+		err = Format(strings.NewReader("a: 1\n---\nb: [\n"), ioutil.Discard, opts)
+		formatErr = nil
+		assert.True(t, errors.As(err, &formatErr))
+		assert.Equal(t, 2, formatErr.DocIndex)
+		assert.True(t, formatErr.Syntax)
+		assert.EqualError(t, err, `document 2: yaml: line 3: did not find expected node content`)
+	}
+
+	// With AllowPartial, every error can be extracted.
+	opts := DefaultOptions()
+	opts.AllowPartial = true
+	err = Format(strings.NewReader("b: 1\nb: 2\n---\na: 1\n---\nc: [\n"), ioutil.Discard, opts)
+	assert.EqualError(t, err, "document 1: line 2: key b collides with key b on line 1\n"+
+		"document 3: yaml: line 6: did not find expected node content")
+	joined, ok := err.(interface{ Unwrap() []error })
+	assert.True(t, ok)
+	positions := []int{}
+	for _, e := range joined.Unwrap() {
+		assert.True(t, errors.As(e, &formatErr))
+		positions = append(positions, formatErr.DocIndex)
+	}
+	assert.Equal(t, []int{1, 3}, positions)
+
+	// Errors of the whole stream aren't.
+	opts = DefaultOptions()
+	opts.SeqIndentStyle = "deep"
+	err = Format(strings.NewReader("a: 1\n"), ioutil.Discard, opts)
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &formatErr))
+}
+
+func TestFormatFromJSON(t *testing.T) {
+	in := `{
+	"name": "web",
+	"ports": [80, 443],
+	"env": {"DEBUG": "true", "LEVEL": "info"},
+	"owner": null
+}
+`
+	expected := `env:
+  DEBUG: "true"
+  LEVEL: info
+name: web
+owner: null
+ports:
+- 80
+- 443
+`
+	opts := DefaultOptions()
+	opts.FromJSON = true
+	opts.KeepFlow = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+
+	assert.EqualError(t, Format(strings.NewReader("{\n\"a\": 1,\n}\n"), &out, opts),
+		"line 3: invalid JSON: invalid character '}' looking for beginning of object key string")
+}
+
+func TestFormatTypedKeys(t *testing.T) {
+	in := `
+"1": string
+true: bool
+1: int
+"true": string
+`
+	expected := `1: int
+"1": string
+true: bool
+"true": string
+`
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, expected, out.String())
+
+	var again bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(out.String()), &again, DefaultOptions()))
+	assert.Equal(t, expected, again.String())
+}
+
+func TestFormatNoStyleNormalize(t *testing.T) {
+	in := `
+name: 'app'
+ports: [80, 443]
+labels: {tier: "web", app: x}
+`
+	expected := `labels: {app: x, tier: "web"}
+name: 'app'
+ports: [80, 443]
+`
+	opts := DefaultOptions()
+	opts.NormalizeStyles = false
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatDocumentEnd(t *testing.T) {
+	in := "b: 1\na: 2\n...\n---\nc: 3\n...\n"
+
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, DefaultOptions()))
+	assert.Equal(t, "a: 2\nb: 1\n---\nc: 3\n", out.String())
+
+	opts := DefaultOptions()
+	opts.ExplicitEnd = true
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, "a: 2\nb: 1\n...\n---\nc: 3\n...\n", out.String())
+}
+
+func TestFormatDocumentStart(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader("---\nb: 1\n---\na: 2\n"), &out, DefaultOptions()))
+	assert.Equal(t, "---\nb: 1\n---\na: 2\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader("b: 1\n---\na: 2\n"), &out, DefaultOptions()))
+	assert.Equal(t, "b: 1\n---\na: 2\n", out.String())
+
+	opts := DefaultOptions()
+	opts.ExplicitStart = true
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader("b: 1\n---\na: 2\n"), &out, opts))
+	assert.Equal(t, "---\nb: 1\n---\na: 2\n", out.String())
+}
+
+func TestFormatDirectives(t *testing.T) {
+	in := `%YAML 1.2
+%TAG !e! tag:example.com,2000:
+---
+b: !e!foo 1
+a: 2
+c: !<tag:example.com,2000:a,b> 3
+d: |
+  !<tag:example.com,2000:foo>
+`
+	expected := `%YAML 1.2
+%TAG !e! tag:example.com,2000:
+---
+a: 2
+b: !e!foo 1
+c: !<tag:example.com,2000:a,b> 3
+d: |
+  !<tag:example.com,2000:foo>
+`
+	opts := DefaultOptions()
+	opts.CheckSemantics = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFormatNoFinalNewline(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader("b: 1\na: 2"), &out, DefaultOptions()))
+	assert.Equal(t, "a: 2\nb: 1\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader("a: |\n  text"), &out, DefaultOptions()))
+	assert.Equal(t, "a: |-\n  text\n", out.String())
+}
+
+func TestFormatDedupDocuments(t *testing.T) {
+	in := `
+kind: Service
+metadata:
+  name: web
+---
+kind: Deployment
+metadata:
+  name: web
+---
+metadata:
+  name: web
+kind: Service
+---
+kind: Service
+metadata:
+  name: web # the same service
+`
+	opts := DefaultOptions()
+	opts.DedupDocuments = true
+	opts.CheckSemantics = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `kind: Deployment
+metadata:
+  name: web
+---
+kind: Service
+metadata:
+  name: web
+---
+kind: Service
+metadata:
+  name: web # the same service
+`, out.String())
+
+	opts.DedupIgnoreComments = true
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `kind: Deployment
+metadata:
+  name: web
+---
+kind: Service
+metadata:
+  name: web
+`, out.String())
+}
+
+func TestFormatMergeDocuments(t *testing.T) {
+	in := `
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+---
+kind: Service
+metadata:
+  name: web
+---
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    tier: frontend
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: sidecar
+`
+	opts := DefaultOptions()
+	opts.MergeDocuments = true
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `kind: Deployment
+metadata:
+  labels:
+    tier: frontend
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: sidecar
+---
+kind: Service
+metadata:
+  name: web
+`, out.String())
+
+	opts.MergeSequences = "append"
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Contains(t, out.String(), "      containers:\n      - name: web\n      - name: sidecar\n")
+
+	// Keys with the same text but different tags are different keys.
+	in = "kind: ConfigMap\nmetadata:\n  name: a\ndata:\n  1: int\n---\nkind: ConfigMap\nmetadata:\n  name: a\ndata:\n  \"1\": str\n"
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, "data:\n  1: int\n  \"1\": str\nkind: ConfigMap\nmetadata:\n  name: a\n", out.String())
+}
+
+func TestFormatSet(t *testing.T) {
+	in := `
+metadata:
+  name: web
+  namespace: dev
+spec:
+  ports: [80]
+`
+	opts := DefaultOptions()
+	opts.Set = map[string]string{
+		"metadata.namespace":     "prod",
+		"metadata.labels.tier":   "frontend",
+		"spec.ports.0":           "8080",
+		"spec.template.replicas": "3",
+	}
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `metadata:
+  labels:
+    tier: frontend
+  name: web
+  namespace: prod
+spec:
+  ports:
+  - 8080
+  template:
+    replicas: 3
+`, out.String())
+
+	opts.Set = map[string]string{"spec.ports": "80"}
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts),
+		"document 1: Cannot set spec.ports: spec.ports is not a scalar")
+	opts.Set = map[string]string{"metadata.name.first": "web"}
+	assert.EqualError(t, Format(strings.NewReader(in), &out, opts),
+		"document 1: Cannot set metadata.name.first: metadata.name is not a mapping or sequence")
+
+	// Keys are matched by tag as well as text.
+	in = "data:\n  !!str 1: str\n  1: int\n"
+	opts.Set = map[string]string{"data.1": "set"}
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, "data:\n  1: int\n  !!str 1: set\n", out.String())
+
+	// Values merged in or reached through an alias are copied before they
+	// are set, so that the anchored node and its other aliases don't change.
+	in = `
+base: &base
+  image: web
+  env: {tier: dev}
+web:
+  <<: *base
+worker:
+  <<: *base
+x: *base
+y: *base
+`
+	opts.Set = map[string]string{"web.image": "api", "worker.env.tier": "prod", "x.image": "db"}
+	out.Reset()
+	assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+	assert.Equal(t, `base: &base
+  env:
+    tier: dev
+  image: web
+web:
+  <<: *base
+  image: api
+worker:
+  <<: *base
+  env:
+    tier: prod
+x:
+  env:
+    tier: dev
+  image: db
+y: *base
+`, out.String())
+}
+
+func TestFormatCanonical(t *testing.T) {
+	inputs := []string{`
+# a deployment
+kind: Deployment
+metadata: {name: web, labels: {app: web}}
+spec:
+  replicas: 0x3
+  paused: True
+  ratio: .5
+  limit: .Inf
+  base: &base
+    image: 'nginx:1.19'
+    note: "a: b"
+  merged:
+    <<: *base
+    cmd: >
+      run
+      fast
+  empty: ~
+  "yes": 'yes'
+  version: "1.0"
+`, `kind: Deployment
+spec:
+  version: '1.0'
+  ratio: 0.50
+  merged: {image: nginx:1.19, note: 'a: b', cmd: "run fast\n"}
+  'yes': "yes"
+  base: {note: "a: b", image: "nginx:1.19"}
+  empty: null
+  paused: true
+  limit: +.inf
+  replicas: 3 # three
+metadata:
+  labels:
+      app: "web"
+  name: web
+`}
+	expected := `kind: Deployment
+metadata:
+  labels:
+    app: web
+  name: web
+spec:
+  base:
+    image: nginx:1.19
+    note: 'a: b'
+  empty: null
+  limit: .inf
+  merged:
+    cmd: |
+      run fast
+    image: nginx:1.19
+    note: 'a: b'
+  paused: true
+  ratio: 0.5
+  replicas: 3
+  version: "1.0"
+  "yes": "yes"
+`
+	for _, in := range inputs {
+		opts := DefaultOptions()
+		opts.Canonical = true
+		opts.Indent = 4
+		opts.KeepFlow = true
+		opts.SortDepth = 1
+		var out bytes.Buffer
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+		assert.Equal(t, expected, out.String())
+	}
+
+	// Explicit tags that the canonical form resolves to anyway are dropped.
+	for _, pair := range [][2]string{
+		{"b: \"1\"\n", "b: !!str 1\n"},
+		{"b: 1\n", "b: !!int \"1\"\n"},
+		{"b: true\n", "b: !!bool \"True\"\n"},
+	} {
+		canonical := []string{}
+		for _, in := range pair {
+			opts := DefaultOptions()
+			opts.Canonical = true
+			var out bytes.Buffer
+			assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+			canonical = append(canonical, out.String())
+		}
+		assert.Equal(t, canonical[0], canonical[1], pair[1])
+		assert.Equal(t, pair[0], canonical[1], pair[1])
+	}
+
+	// Only the whole input has a canonical form.
+	opts := DefaultOptions()
+	opts.Canonical = true
+	opts.Path = "b"
+	assert.EqualError(t, Format(strings.NewReader("b: {y: 1, x: 2}\n"), ioutil.Discard, opts),
+		"Canonical can't be combined with Path")
+}
+
+func TestOptions(t *testing.T) {
+	in := `
+kind: Service
+metadata:
+  name: b
+  labels: {}
+  annotations: null
+  namespace: " default "
+spec:
+  ports: &ports [80, 443]
+  targetPorts: *ports
+  weight: .5
+  image: "nginx"
+---
+kind: Deployment
+metadata:
+  name: a
+`
+	format := func(set func(*Options)) string {
+		opts := DefaultOptions()
+		set(&opts)
+		var out bytes.Buffer
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts))
+		return out.String()
+	}
+	defaults := func(o *Options) {}
+	keepFlow := func(o *Options) { o.KeepFlow = true }
+
+	for _, c := range []struct {
+		field string
+		base  func(*Options)
+		set   func(*Options)
+	}{
+		{"Indent", defaults, func(o *Options) { o.Indent = 4 }},
+		{"Canonical", defaults, func(o *Options) { o.Canonical = true }},
+		{"SortDocuments", defaults, func(o *Options) { o.SortDocuments = false }},
+		{"ReverseDocuments", defaults, func(o *Options) { o.ReverseDocuments = true }},
+		{"DescendingKeys", defaults, func(o *Options) { o.DescendingKeys = true }},
+		{"GroupDocuments", defaults, func(o *Options) { o.GroupDocuments = true }},
+		{"SortKeys", defaults, func(o *Options) { o.SortKeys = false }},
+		{"SortDepth", defaults, func(o *Options) { o.SortDepth = 1 }},
+		{"SortPaths", defaults, func(o *Options) { o.SortPaths = []string{"spec"} }},
+		{"KeyOrder", defaults, func(o *Options) { o.KeyOrder = map[string][]string{"metadata": {"name"}} }},
+		{"AllowedKeys", defaults, func(o *Options) { o.AllowedKeys = map[string][]string{"": {"kind"}} }},
+		{"ValueTransforms", defaults, func(o *Options) { o.ValueTransforms = map[string]string{"metadata.name": "upper"} }},
+		{"KeyTransforms", defaults, func(o *Options) { o.KeyTransforms = map[string]string{"": "upper"} }},
+		{"NormalizeStyles", defaults, func(o *Options) { o.NormalizeStyles = false }},
+		{"PreserveQuotes", defaults, func(o *Options) { o.PreserveQuotes = []string{"spec.image"} }},
+		{"KeepFlow", defaults, keepFlow},
+		{"KeepFlowMax", keepFlow, func(o *Options) { o.KeepFlow, o.KeepFlowMax = true, 1 }},
+		{"FlowFoldThreshold", defaults, func(o *Options) { o.FlowFoldThreshold = 5 }},
+		{"SeqStyle", defaults, func(o *Options) { o.SeqStyle = "flow" }},
+		{"SeqIndentStyle", defaults, func(o *Options) { o.SeqIndentStyle = "indent" }},
+		{"IndentStyle", defaults, func(o *Options) { o.IndentStyle = "tab" }},
+		{"ExpandAliases", defaults, func(o *Options) { o.ExpandAliases = true }},
+		{"NormalizeFloats", defaults, func(o *Options) { o.NormalizeFloats = true }},
+		{"NullStyle", defaults, func(o *Options) { o.NullStyle = "tilde" }},
+		{"TrimStrings", defaults, func(o *Options) { o.TrimStrings = true }},
+		{"Set", defaults, func(o *Options) { o.Set = map[string]string{"metadata.name": "c"} }},
+		{"ExplicitStart", defaults, func(o *Options) { o.ExplicitStart = true }},
+		{"ExplicitEnd", defaults, func(o *Options) { o.ExplicitEnd = true }},
+		{"PruneNull", defaults, func(o *Options) { o.PruneNull = true }},
+		{"PruneEmpty", defaults, func(o *Options) { o.PruneEmpty = true }},
+	} {
+		assert.NotEqual(t, format(c.base), format(c.set), c.field)
+	}
+}
@@ -0,0 +1,80 @@
+//go:build go1.18
+// +build go1.18
+
+package format
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FuzzFormat checks that Format never panics on arbitrary input, both with
+// the default options and streaming unsorted documents, and that when it
+// succeeds its output parses again, and decodes again into as many documents
+// if the input decodes. Run it with
+// go test -fuzz FuzzFormat ./pkg/format.
+func FuzzFormat(f *testing.F) {
+	for _, seed := range []string{
+		"b: 1\na: 2\n",
+		"a: &x {b: 1}\nc: *x\n",
+		"base: &base\n  a: 1\nderived:\n  <<: *base\n  b: 2\n",
+		"a: 1\n---\nb: [2, 3]\n...\n---\n- c\n- d: e\n",
+		"# head\nkey: |\n  text\n\n- x\n",
+		"{a: [1, {b: 2}], c: !!str 3}\n",
+		// Invalid UTF-8 after a tag used to panic in the parser.
+		"!00 \xec",
+		// Comments ending in a multibyte character used to panic in the
+		// encoder.
+		"a: 1 # café\n",
+		"a: 1\r# café\rb: 2\r",
+		// The tag !! can't be written back.
+		"a: !%21 1\n",
+		// An empty document needs a "---" to stay a document.
+		"!",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		streamed := DefaultOptions()
+		streamed.SortDocuments = false
+		for _, opts := range []Options{DefaultOptions(), streamed} {
+			var out bytes.Buffer
+			if err := Format(bytes.NewReader(in), &out, opts); err != nil {
+				continue
+			}
+			if err := parseAll(out.Bytes()); err != nil {
+				t.Fatalf("output of %q doesn't parse: %v\n%s", in, err, out.String())
+			}
+			docs, err := decodeAll(in)
+			if err != nil {
+				continue
+			}
+			formatted, err := decodeAll(out.Bytes())
+			if err != nil {
+				t.Fatalf("output of %q doesn't decode: %v\n%s", in, err, out.String())
+			}
+			if len(formatted) != len(docs) {
+				t.Fatalf("output of %q has %d documents instead of %d\n%s", in, len(formatted), len(docs), out.String())
+			}
+		}
+	})
+}
+
+// parseAll fails if a document of the YAML stream b doesn't parse into
+// nodes.
+func parseAll(b []byte) error {
+	d := yaml.NewDecoder(bytes.NewReader(withoutVersion(b)))
+	for {
+		var doc yaml.Node
+		err := d.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
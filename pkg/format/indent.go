@@ -0,0 +1,278 @@
+package format
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// indentWidth returns the number of columns by which the block mappings of
+// doc are most often indented under their keys in the input, or 0 if doc
+// has no nested block mappings. Sequences aren't counted, since their dashes
+// are commonly flush with their keys.
+func indentWidth(doc *yaml.Node) int {
+	counts := map[int]int{}
+	var count func(node *yaml.Node)
+	count = func(node *yaml.Node) {
+		if node.Kind&yaml.MappingNode > 0 && node.Style&yaml.FlowStyle == 0 {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if value.Kind&yaml.MappingNode > 0 && value.Style&yaml.FlowStyle == 0 &&
+					len(value.Content) > 0 && value.Content[0].Line > key.Line {
+					counts[value.Content[0].Column-key.Column]++
+				}
+			}
+		}
+		for _, child := range node.Content {
+			count(child)
+		}
+	}
+	count(doc)
+
+	width := 0
+	for w, n := range counts {
+		if w > 0 && (n > counts[width] || n == counts[width] && w < width) {
+			width = w
+		}
+	}
+	return width
+}
+
+// seqIndentStyles are the values of Options.SeqIndentStyle.
+var seqIndentStyles = map[string]bool{
+	"":       true,
+	"flush":  true,
+	"indent": true,
+	"nested": true,
+}
+
+// indentStyles are the values of Options.IndentStyle.
+var indentStyles = map[string]bool{
+	"":      true,
+	"space": true,
+	"tab":   true,
+}
+
+// blockHeader matches the end of a line that starts a block scalar, such as
+// "key: |-", "- >" or "key: &anchor |2 # comment".
+var blockHeader = regexp.MustCompile(`(?:^|: |- )(?:[!&]\S* +)*[|>][-+1-9]*(?: +#.*)?$`)
+
+// keyLine matches the end of a line that ends with a mapping key whose value
+// is on the next lines, such as "key:", "- key: &anchor" or "key: # comment".
+var keyLine = regexp.MustCompile(`:(?: +[&!]\S*)*(?: +#.*)?$`)
+
+// indentSequences reindents the encoded document text so that the block
+// sequences that are values of mapping keys are placed as SeqIndentStyle
+// says, or indent-2 columns to the right of their keys if style is empty.
+// The encoder indents them by a number of columns that depends on the
+// column of the key. A sequence is at the first level if no block sequence
+// contains it, including a sequence at the top of the document; sequences
+// nested in items of sequences as in "- - a" stay where the encoder puts
+// them, relative to the item. Every line of a sequence moves along with its
+// dashes, so the relative indentation of its items, and of block scalars in
+// them, is kept.
+func indentSequences(text string, indent int, style string) string {
+	lines := strings.Split(text, "\n")
+	body := blockScalarLines(lines)
+
+	// shift[i] is the number of columns line i moves to the right.
+	shift := make([]int, len(lines)+1)
+	type sequence struct{ end, column int }
+	open := []sequence{}
+	topSeq := false
+	prev := -1
+	for i, line := range lines {
+		for len(open) > 0 && open[len(open)-1].end <= i {
+			open = open[:len(open)-1]
+		}
+		if body[i] || isBlank(line) || isComment(line) {
+			continue
+		}
+		ws := leadingSpaces(line)
+		if prev < 0 && isDash(line, ws) {
+			topSeq = true
+		}
+		if prev >= 0 && isDash(line, ws) && ws > contentColumn(lines[prev]) && keyLine.MatchString(lines[prev]) {
+			start, end := sequenceLines(lines, body, prev, i, ws)
+			depth := len(open) + 1
+			if topSeq {
+				depth++
+			}
+			want := indent
+			if style == "" {
+				want = indent - 2
+			} else if style == "flush" || style == "nested" && depth == 1 {
+				want = 0
+			}
+			move := want - (ws - contentColumn(lines[prev]))
+			shift[start] += move
+			shift[end] -= move
+			open = append(open, sequence{end, ws})
+		}
+		prev = i
+	}
+
+	moved := 0
+	for i, line := range lines {
+		moved += shift[i]
+		if line == "" || moved == 0 {
+			continue
+		}
+		if moved > 0 {
+			lines[i] = strings.Repeat(" ", moved) + line
+		} else if leadingSpaces(line) >= -moved {
+			lines[i] = line[-moved:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sequenceLines returns the range of lines [start, end) of the block
+// sequence whose first dash is on line first at column c, after the key on
+// line key. Comments between the key and the first dash belong to the
+// sequence, and blank lines and comments after its last item don't.
+func sequenceLines(lines []string, body []bool, key int, first int, c int) (int, int) {
+	start := first
+	for start-1 > key && isComment(lines[start-1]) && leadingSpaces(lines[start-1]) >= c {
+		start--
+	}
+	end := first + 1
+	last := first
+	for ; end < len(lines); end++ {
+		line := lines[end]
+		if body[end] {
+			last = end
+			continue
+		}
+		if isBlank(line) || isComment(line) {
+			continue
+		}
+		ws := leadingSpaces(line)
+		if ws < c || ws == c && !isDash(line, ws) {
+			break
+		}
+		last = end
+	}
+	return start, last + 1
+}
+
+// trimEmptyValues removes the space that the encoder writes after a key, a
+// dash, an anchor or a tag that is followed by an empty value, such as a
+// null in NullStyle "empty", so that "key: " becomes "key:". Block scalars
+// are left as they are.
+func trimEmptyValues(text string) string {
+	lines := strings.Split(text, "\n")
+	body := blockScalarLines(lines)
+	for i, line := range lines {
+		if body[i] || !strings.HasSuffix(line, " ") {
+			continue
+		}
+		trimmed := line[:len(line)-1]
+		fields := strings.Fields(trimmed)
+		if strings.HasSuffix(trimmed, ":") || isDash(line, len(line)-2) ||
+			len(fields) > 1 && strings.ContainsAny(fields[len(fields)-1][:1], "&!") {
+			lines[i] = trimmed
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentTabs replaces every indent leading spaces of the lines of the
+// encoded document text by a tab. The lines in the bodies of block scalars
+// are left as they are, since their spaces are content.
+func indentTabs(text string, indent int) string {
+	if indent < 1 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	body := blockScalarLines(lines)
+	for i, line := range lines {
+		if body[i] {
+			continue
+		}
+		ws := leadingSpaces(line)
+		lines[i] = strings.Repeat("\t", ws/indent) + line[ws/indent*indent:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// blockScalarLines reports for every line whether it is in the body of a
+// block scalar.
+func blockScalarLines(lines []string) []bool {
+	body := make([]bool, len(lines))
+	for i := 0; i < len(lines); i++ {
+		if isComment(lines[i]) || !blockHeader.MatchString(strings.TrimLeft(lines[i], " ")) {
+			continue
+		}
+		parent := headerColumn(lines[i])
+		for i+1 < len(lines) && (isBlank(lines[i+1]) || leadingSpaces(lines[i+1]) > parent) {
+			i++
+			body[i] = true
+		}
+		// Trailing blank lines separate the scalar from what follows.
+		for j := i; j > 0 && body[j] && isBlank(lines[j]); j-- {
+			body[j] = false
+		}
+	}
+	return body
+}
+
+// headerColumn returns the column of the node that the block scalar started
+// on line belongs to: the key of "key: |", or the last dash of "- |". Its
+// body is indented further than that.
+func headerColumn(line string) int {
+	dash, column := -1, leadingSpaces(line)
+	for isDash(line, column) {
+		dash = column
+		column++
+		for column < len(line) && line[column] == ' ' {
+			column++
+		}
+	}
+	if dash >= 0 && strings.ContainsAny(line[column:column+1], "|>!&") {
+		return dash
+	}
+	return column
+}
+
+// contentColumn returns the column at which the content of line starts after
+// its indentation and the dashes of sequence items.
+func contentColumn(line string) int {
+	i := leadingSpaces(line)
+	for isDash(line, i) {
+		i++
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+	}
+	return i
+}
+
+// isDash reports whether line has a sequence item dash at column i.
+func isDash(line string, i int) bool {
+	return i < len(line) && line[i] == '-' && (i+1 == len(line) || line[i+1] == ' ')
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " "), "#")
+}
+
+// isEmptyText reports whether the encoded document text has only blank
+// lines and comments.
+func isEmptyText(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if !isBlank(line) && !isComment(line) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Issue is a problem that Lint found on a line of a YAML stream.
+type Issue struct {
+	// Line is the line of the problem, starting at 1.
+	Line int
+	// Message describes the problem.
+	Message string
+}
+
+// Lint reads a YAML stream from r and returns the issues it finds, ordered
+// by line: tabs in the indentation outside of block scalars, trailing
+// whitespace, colliding mapping keys, and plain values such as yes and off
+// that YAML 1.1 parsers read as booleans instead of strings. A syntax error
+// is returned as an error, unless it is caused by a tab that is reported as
+// an issue.
+func Lint(r io.Reader) ([]Issue, error) {
+	source, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(source), "\n")
+	body := blockScalarLines(lines)
+	issues := []Issue{}
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if !body[i] && tabIndented(line) {
+			issues = append(issues, Issue{Line: i + 1, Message: tabMessage})
+		}
+		if strings.TrimRight(line, " \t") != line {
+			issues = append(issues, Issue{Line: i + 1, Message: "trailing whitespace"})
+		}
+	}
+
+	d := yaml.NewDecoder(bytes.NewReader(withoutVersion(source)))
+	for {
+		var doc yaml.Node
+		err := decode(d, &doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The tab is already reported, and the rest of the stream can't
+			// be decoded.
+			if tabError(lines, err) != err {
+				break
+			}
+			return nil, err
+		}
+		issues = append(issues, lintNode(&doc)...)
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Line < issues[j].Line
+	})
+	return issues, nil
+}
+
+// lintNode returns the colliding keys and the plain YAML 1.1 booleans in
+// node and its descendants.
+func lintNode(node *yaml.Node) []Issue {
+	issues := []Issue{}
+	if node.Kind&yaml.MappingNode > 0 {
+		tuples, err := tuples(node.Content)
+		if err != nil {
+			return []Issue{{Line: node.Line, Message: err.Error()}}
+		}
+		issues = append(issues, duplicateKeys(tuples)...)
+		for _, tuple := range tuples {
+			issues = append(issues, lintNode(tuple.Key)...)
+			if isYAML11Bool(tuple.Value) {
+				issues = append(issues, boolIssue(tuple.Value))
+			}
+			issues = append(issues, lintNode(tuple.Value)...)
+		}
+		return issues
+	}
+	for _, child := range node.Content {
+		if node.Kind&yaml.SequenceNode > 0 && isYAML11Bool(child) {
+			issues = append(issues, boolIssue(child))
+		}
+		issues = append(issues, lintNode(child)...)
+	}
+	return issues
+}
+
+// isYAML11Bool reports whether node is a plain, untagged string that YAML
+// 1.1 parsers read as a boolean.
+func isYAML11Bool(node *yaml.Node) bool {
+	return node.Kind&yaml.ScalarNode > 0 && node.Style == 0 && node.ShortTag() == "!!str" && yaml11Bools[node.Value]
+}
+
+func boolIssue(node *yaml.Node) Issue {
+	return Issue{
+		Line:    node.Line,
+		Message: fmt.Sprintf("value %s is a string in YAML 1.2 but a boolean in YAML 1.1, quote it", node.Value),
+	}
+}
@@ -0,0 +1,39 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	bad := "env: prod \n" +
+		"\"env\": dev\n" +
+		"enabled: yes\n" +
+		"quoted: \"no\"\n" +
+		"flags: [on, \"off\"]\n" +
+		"script: |\n" +
+		"  make\n" +
+		"---\n" +
+		"a: !!str off\n"
+	issues, err := Lint(strings.NewReader(bad))
+	assert.NoError(t, err)
+	assert.Equal(t, []Issue{
+		{Line: 1, Message: "trailing whitespace"},
+		{Line: 2, Message: "key \"env\" collides with key env on line 1"},
+		{Line: 3, Message: "value yes is a string in YAML 1.2 but a boolean in YAML 1.1, quote it"},
+		{Line: 5, Message: "value on is a string in YAML 1.2 but a boolean in YAML 1.1, quote it"},
+	}, issues)
+
+	issues, err = Lint(strings.NewReader("a:\n\tb: 1\nc: |\n  \tkept\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Issue{{Line: 2, Message: tabMessage}}, issues)
+
+	issues, err = Lint(strings.NewReader("a: 1\nb: 2\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+
+	_, err = Lint(strings.NewReader("a: [\n"))
+	assert.Error(t, err)
+}
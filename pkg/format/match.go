@@ -0,0 +1,39 @@
+package format
+
+import (
+	"path"
+	"strings"
+)
+
+// matchPath reports whether the dotted path pattern matches p. The empty
+// pattern matches the empty path of the top-level node.
+func matchPath(pattern string, p []string) bool {
+	if pattern == "" {
+		return len(p) == 0
+	}
+	return Match(strings.Split(pattern, "."), p)
+}
+
+// Match reports whether the pattern segments match the path segments p.
+// Each segment of the pattern is matched with path.Match, and a "**"
+// segment matches any number of path segments.
+func Match(pattern []string, p []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(p); i >= 0; i-- {
+				if Match(pattern[1:], p[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(p) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], p[0]); err != nil || !ok {
+			return false
+		}
+		pattern, p = pattern[1:], p[1:]
+	}
+	return len(p) == 0
+}
@@ -0,0 +1,17 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPath(t *testing.T) {
+	assert.True(t, matchPath("spec.containers.*", []string{"spec", "containers", "0"}))
+	assert.False(t, matchPath("spec.containers.*", []string{"spec", "containers"}))
+	assert.True(t, matchPath("**.labels", []string{"metadata", "labels"}))
+	assert.True(t, matchPath("**.labels", []string{"labels"}))
+	assert.False(t, matchPath("**.labels", []string{"metadata", "labels", "app"}))
+	assert.True(t, matchPath("", []string{}))
+	assert.False(t, matchPath("", []string{"metadata"}))
+}
@@ -0,0 +1,79 @@
+package format
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// mergeDocuments deep-merges the documents with the same kind, namespace and
+// name into the first of them, with later documents overriding earlier ones,
+// and returns the remaining documents in their order. Documents without a
+// kind or a name are kept as they are. Sequences of merged documents are
+// replaced, or concatenated with appendSequences.
+func mergeDocuments(docs []*yaml.Node, appendSequences bool) []*yaml.Node {
+	first := map[string]*yaml.Node{}
+	kept := []*yaml.Node{}
+	for _, doc := range docs {
+		id, ok := documentID(doc)
+		if !ok {
+			kept = append(kept, doc)
+			continue
+		}
+		if target, ok := first[id]; ok {
+			mergeNodes(target.Content[0], doc.Content[0], appendSequences)
+			continue
+		}
+		first[id] = doc
+		kept = append(kept, doc)
+	}
+	return kept
+}
+
+// documentID returns the kind, namespace and name of doc as one string, and
+// whether doc has a kind and a name.
+func documentID(doc *yaml.Node) (string, bool) {
+	kind, err := traverse(doc, "kind")
+	if err != nil || kind.Kind&yaml.ScalarNode == 0 {
+		return "", false
+	}
+	name, err := traverse(doc, "metadata", "name")
+	if err != nil || name.Kind&yaml.ScalarNode == 0 {
+		return "", false
+	}
+	namespace := ""
+	if node, err := traverse(doc, "metadata", "namespace"); err == nil {
+		namespace = node.Value
+	}
+	return kind.Value + "\x00" + namespace + "\x00" + name.Value, true
+}
+
+// mergeNodes merges the mapping src into the mapping dst. A key of src that
+// dst has, with the same tag and value, is merged into its value if both
+// values are mappings, or with appendSequences if both are sequences, and
+// replaces the value otherwise. Other keys of src are added to dst.
+func mergeNodes(dst *yaml.Node, src *yaml.Node, appendSequences bool) {
+	dst, src = resolve(dst), resolve(src)
+	if dst.Kind&yaml.MappingNode == 0 || src.Kind&yaml.MappingNode == 0 {
+		return
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		j := 0
+		for j+1 < len(dst.Content) && keyOf(dst.Content[j]) != keyOf(key) {
+			j += 2
+		}
+		if j+1 >= len(dst.Content) {
+			dst.Content = append(dst.Content, key, value)
+			continue
+		}
+		old := resolve(dst.Content[j+1])
+		v := resolve(value)
+		switch {
+		case old.Kind&yaml.MappingNode > 0 && v.Kind&yaml.MappingNode > 0:
+			mergeNodes(old, v, appendSequences)
+		case appendSequences && old.Kind&yaml.SequenceNode > 0 && v.Kind&yaml.SequenceNode > 0:
+			old.Content = append(old.Content, v.Content...)
+		default:
+			dst.Content[j+1] = value
+		}
+	}
+}
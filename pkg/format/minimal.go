@@ -0,0 +1,143 @@
+package format
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceSpans records in spans the source lines of every entry of the
+// top-level block mapping of doc, keyed by the entry's key, and the comments
+// before the first entry, keyed by the mapping. An entry runs from the
+// comments right above its key to the line before the next entry, and the
+// last entry to the last line of the document that isn't blank. The
+// comments before the first document of the stream, given by first, include
+// those before its "---".
+func sourceSpans(doc *yaml.Node, lines []string, first bool, spans map[*yaml.Node]string) {
+	if len(doc.Content) == 0 {
+		return
+	}
+	m := doc.Content[0]
+	if m.Kind&yaml.MappingNode == 0 || m.Style&yaml.FlowStyle > 0 || len(m.Content) == 0 {
+		return
+	}
+	starts := []int{}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		start := m.Content[i].Line - 1
+		for start > 0 && strings.HasPrefix(lines[start-1], "#") {
+			start--
+		}
+		starts = append(starts, start)
+	}
+	end := m.Content[len(m.Content)-2].Line
+	for end < len(lines) && !isMarker(lines[end]) {
+		end++
+	}
+	for end > m.Content[len(m.Content)-2].Line && isBlank(lines[end-1]) {
+		end--
+	}
+	starts = append(starts, end)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		spans[m.Content[i]] = strings.Join(lines[starts[i/2]:starts[i/2+1]], "\n") + "\n"
+	}
+
+	prefix := []string{}
+	for i := starts[0] - 1; i >= 0; i-- {
+		line := lines[i]
+		if first && (isMarker(line) || strings.HasPrefix(line, "%")) {
+			continue
+		}
+		if !isBlank(line) && !isComment(line) {
+			break
+		}
+		prefix = append([]string{line}, prefix...)
+	}
+	for len(prefix) > 0 && isBlank(prefix[0]) {
+		prefix = prefix[1:]
+	}
+	spans[m] = ""
+	if len(prefix) > 0 {
+		spans[m] = strings.Join(prefix, "\n") + "\n"
+	}
+}
+
+// isMarker reports whether line starts or ends a document.
+func isMarker(line string) bool {
+	line = strings.TrimSuffix(line, "\r")
+	for _, marker := range []string{"---", "..."} {
+		if line == marker || strings.HasPrefix(line, marker+" ") || strings.HasPrefix(line, marker+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeMinimal returns the text of doc with the entries of its top-level
+// mapping in their formatted order, where every entry with the same keys in
+// the same order, values and anchors as its source span keeps the text of
+// the span, and the other entries are encoded. Comments and styles aren't
+// compared. It returns false if spans has no source for doc.
+func encodeMinimal(doc *yaml.Node, spans map[*yaml.Node]string, opts *Options) (string, bool, error) {
+	if len(doc.Content) == 0 {
+		return "", false, nil
+	}
+	m := doc.Content[0]
+	prefix, ok := spans[m]
+	if !ok {
+		return "", false, nil
+	}
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, value := m.Content[i], m.Content[i+1]
+		if span, ok := spans[key]; ok && sameEntry(span, key, value) {
+			b.WriteString(span)
+			continue
+		}
+		entry := *m
+		entry.Content = []*yaml.Node{key, value}
+		entry.HeadComment, entry.LineComment, entry.FootComment = "", "", ""
+		text, err := encodeNode(&entry, opts)
+		if err != nil {
+			return "", false, err
+		}
+		b.WriteString(text)
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n", true, nil
+}
+
+// sameEntry reports whether the mapping entry in span decodes to key and
+// value.
+func sameEntry(span string, key *yaml.Node, value *yaml.Node) bool {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(span), &doc); err != nil || len(doc.Content) == 0 {
+		return false
+	}
+	m := doc.Content[0]
+	return m.Kind&yaml.MappingNode > 0 && len(m.Content) == 2 &&
+		sameNode(m.Content[0], key) && sameNode(m.Content[1], value)
+}
+
+// sameNode reports whether a and b have the same kinds, anchors and values,
+// with mapping keys in the same order. Aliases are never the same, since
+// the anchors they refer to may have moved.
+func sameNode(a *yaml.Node, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Anchor != b.Anchor || a.Kind&yaml.AliasNode > 0 ||
+		len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind&yaml.ScalarNode > 0 {
+		var va, vb interface{}
+		if a.ShortTag() != b.ShortTag() || a.Decode(&va) != nil || b.Decode(&vb) != nil {
+			return false
+		}
+		return reflect.DeepEqual(va, vb)
+	}
+	for i := range a.Content {
+		if !sameNode(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,667 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type queueItem struct {
+	Node   *yaml.Node
+	Path   []string
+	Indent int
+	Key    bool
+}
+
+type tupleItem struct {
+	Key   *yaml.Node
+	Value *yaml.Node
+}
+
+// prune removes the mapping entries whose value is null with PruneNull, and
+// those whose value is an empty mapping or sequence with PruneEmpty. It
+// works bottom-up, so entries that only become empty after pruning their
+// children are removed as well. Anchored values are never removed.
+func prune(node *yaml.Node, opts *Options) {
+	if !opts.PruneNull && !opts.PruneEmpty {
+		return
+	}
+	for _, child := range node.Content {
+		prune(child, opts)
+	}
+	if node.Kind&yaml.MappingNode == 0 {
+		return
+	}
+	tuples, err := tuples(node.Content)
+	if err != nil {
+		return
+	}
+	kept := []tupleItem{}
+	for _, tuple := range tuples {
+		if !prunable(tuple.Value, opts) {
+			kept = append(kept, tuple)
+		}
+	}
+	node.Content = contents(kept)
+}
+
+func prunable(node *yaml.Node, opts *Options) bool {
+	if node.Anchor != "" {
+		return false
+	}
+	if opts.PruneNull && node.Kind&yaml.ScalarNode > 0 && node.ShortTag() == "!!null" {
+		return true
+	}
+	return opts.PruneEmpty && node.Kind&(yaml.MappingNode|yaml.SequenceNode) > 0 && len(node.Content) == 0
+}
+
+// normalize rewrites the tags, values and styles of the nodes below root and
+// sorts the keys of its mappings. It fails on a mapping with two keys that
+// are equal once decoded, which the output would no longer tell apart, and
+// on a malformed mapping with an odd number of nodes.
+func normalize(root queueItem, opts *Options) error {
+	stack := []queueItem{root}
+	var top queueItem
+	for len(stack) > 0 {
+		top, stack = stack[0], stack[1:]
+		if opts.Debug && opts.DebugFormat == "json" {
+			printNodeJSON(opts.DebugOutput, top.Node, top.Path, top.Indent)
+		} else if opts.Debug {
+			printNode(opts.DebugOutput, top.Node, top.Path, top.Indent)
+		}
+		if err := normalizeTag(top.Node); err != nil {
+			return err
+		}
+		normalizeBlockStyle(top.Node)
+		if opts.NormalizeComments {
+			normalizeComments(top.Node, opts.KeepCommentPrefixes)
+		}
+		normalizeScalar(&top, opts)
+		if opts.NormalizeStyles {
+			normalizeStyle(&top, opts)
+		}
+		normalizeSeqStyle(top.Node, opts)
+		if opts.Canonical {
+			canonicalNode(&top)
+		}
+
+		content := []queueItem{}
+
+		if top.Node.Kind&yaml.SequenceNode > 0 {
+			if field := sortField(opts.SortSequences, top.Path); field != "" {
+				sortItems(top.Node, field)
+			}
+			for index, child := range top.Node.Content {
+				path := append([]string{}, top.Path...)
+				content = append(content, queueItem{Node: child, Path: append(path, strconv.Itoa(index)), Indent: top.Indent + 1})
+			}
+		} else if top.Node.Kind&yaml.MappingNode > 0 {
+			tuples, err := tuples(top.Node.Content)
+			if err != nil {
+				return fmt.Errorf("line %d: %v", top.Node.Line, err)
+			}
+			if err := checkDuplicateKeys(tuples); err != nil {
+				return err
+			}
+			if err := transformKeys(tuples, top.Path, opts); err != nil {
+				return err
+			}
+			if allowed := allowedKeys(opts.AllowedKeys, top.Path); allowed != nil {
+				tuples = dropKeys(tuples, allowed, top.Path, opts)
+				top.Node.Content = contents(tuples)
+			}
+			for _, tuple := range tuples {
+				content = append(content, queueItem{Node: tuple.Key, Path: top.Path, Indent: top.Indent + 1, Key: true})
+				path := append([]string{}, top.Path...)
+				content = append(content, queueItem{Node: tuple.Value, Path: append(path, tuple.Key.Value), Indent: top.Indent + 1})
+			}
+			if sortsKeys(&top, opts) {
+				order := priorityKeys(opts.KeyOrder, top.Path)
+				byName := opts.SortLabelsByName && isLabels(top.Path)
+				less := func(a *yaml.Node, b *yaml.Node) bool {
+					return lessKey(a, b, order, byName, opts.DescendingKeys)
+				}
+				if opts.KeepRegions {
+					sortRegions(tuples, less)
+				} else {
+					sort.Slice(tuples, func(i, j int) bool {
+						return less(tuples[i].Key, tuples[j].Key)
+					})
+				}
+				top.Node.Content = contents(tuples)
+			}
+		} else {
+			for _, child := range top.Node.Content {
+				content = append(content, queueItem{Node: child, Path: top.Path, Indent: top.Indent + 1})
+			}
+		}
+
+		stack = append(content, stack...)
+	}
+	return nil
+}
+
+// sortField returns the field by which the items of a sequence at path p are
+// sorted, or "" if they aren't. If several patterns of sortSequences match,
+// the lexically first one wins.
+func sortField(sortSequences map[string]string, p []string) string {
+	patterns := sortedPatterns(sortSequences)
+	if pattern, ok := firstMatch(patterns, p); ok {
+		return sortSequences[pattern]
+	}
+	return ""
+}
+
+// sortItems sorts the items of the sequence node by the scalar value of
+// their field, keeping the order of items with equal values. Items that
+// aren't mappings with a scalar field come last in their order.
+func sortItems(node *yaml.Node, field string) {
+	value := func(item *yaml.Node) (string, bool) {
+		item = resolve(item)
+		if item.Kind&yaml.MappingNode == 0 {
+			return "", false
+		}
+		v, err := lookup(item, field)
+		if err != nil || v == nil || resolve(v).Kind&yaml.ScalarNode == 0 {
+			return "", false
+		}
+		return resolve(v).Value, true
+	}
+	sort.SliceStable(node.Content, func(i, j int) bool {
+		v_i, ok_i := value(node.Content[i])
+		v_j, ok_j := value(node.Content[j])
+		if ok_i != ok_j {
+			return ok_i
+		}
+		return v_i < v_j
+	})
+}
+
+// checkDuplicateKeys fails if two scalar keys of a mapping, other than merge
+// keys, decode to the same value, such as env and "env".
+func checkDuplicateKeys(tuples []tupleItem) error {
+	if issues := duplicateKeys(tuples); len(issues) > 0 {
+		return fmt.Errorf("line %d: %s", issues[0].Line, issues[0].Message)
+	}
+	return nil
+}
+
+// transformKeys applies the transform of the lexically first pattern of
+// KeyTransforms that matches the path p of a mapping to its string keys,
+// other than merge keys. It fails if two keys are equal once transformed,
+// such as Env and env with "lower", naming both keys as they were.
+func transformKeys(tuples []tupleItem, p []string, opts *Options) error {
+	patterns := sortedPatterns(opts.KeyTransforms)
+	pattern, ok := firstMatch(patterns, p)
+	if !ok {
+		return nil
+	}
+	transform := valueTransforms[opts.KeyTransforms[pattern]]
+	seen := map[mapKey]*yaml.Node{}
+	values := map[*yaml.Node]string{}
+	for _, tuple := range tuples {
+		key := tuple.Key
+		if key.Kind&yaml.ScalarNode == 0 || isMergeKey(key) {
+			continue
+		}
+		value := key.Value
+		if key.ShortTag() == "!!str" {
+			value = transform(value)
+		}
+		k := mapKey{Tag: key.ShortTag(), Value: value}
+		if first, ok := seen[k]; ok {
+			return fmt.Errorf("line %d: key %s collides with key %s on line %d once transformed by %q",
+				key.Line, presentation(key), presentation(first), first.Line, opts.KeyTransforms[pattern])
+		}
+		seen[k] = key
+		values[key] = value
+	}
+	for key, value := range values {
+		key.Value = value
+	}
+	return nil
+}
+
+// duplicateKeys returns an Issue for every scalar key of a mapping, other
+// than merge keys, that decodes to the same value as an earlier key.
+func duplicateKeys(tuples []tupleItem) []Issue {
+	issues := []Issue{}
+	seen := map[mapKey]*yaml.Node{}
+	for _, tuple := range tuples {
+		if tuple.Key.Kind&yaml.ScalarNode == 0 || isMergeKey(tuple.Key) {
+			continue
+		}
+		if first, ok := seen[keyOf(tuple.Key)]; ok {
+			issues = append(issues, Issue{
+				Line:    tuple.Key.Line,
+				Message: fmt.Sprintf("key %s collides with key %s on line %d", presentation(tuple.Key), presentation(first), first.Line),
+			})
+			continue
+		}
+		seen[keyOf(tuple.Key)] = tuple.Key
+	}
+	return issues
+}
+
+// presentation returns the text of a scalar with the quotes it was written
+// with.
+func presentation(node *yaml.Node) string {
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle > 0:
+		return strconv.Quote(node.Value)
+	case node.Style&yaml.SingleQuotedStyle > 0:
+		return "'" + strings.Replace(node.Value, "'", "''", -1) + "'"
+	}
+	return node.Value
+}
+
+// normalizeTag drops explicit tags that restate the tag a node resolves to
+// anyway, and keeps all other explicit tags, including custom ones. It fails
+// for the tag "!!", such as !%21 or !<tag:yaml.org,2002:> in the input,
+// which the encoder writes as a "!!" shorthand without a suffix that doesn't
+// parse.
+func normalizeTag(node *yaml.Node) error {
+	if node.Style&yaml.TaggedStyle == 0 {
+		return nil
+	}
+	implicit := &yaml.Node{Kind: node.Kind, Style: node.Style ^ yaml.TaggedStyle, Value: node.Value}
+	if implicit.ShortTag() == node.ShortTag() {
+		node.Style = node.Style ^ yaml.TaggedStyle
+		return nil
+	}
+	if node.ShortTag() == "!!" {
+		return fmt.Errorf("line %d: cannot write the tag !!", node.Line)
+	}
+	// The encoder only expands the "!!" shorthand on scalars, so spell out
+	// the tags of collections in full.
+	if node.Kind != yaml.ScalarNode && strings.HasPrefix(node.Tag, "!!") {
+		node.Tag = "tag:yaml.org,2002:" + node.Tag[2:]
+	}
+	return nil
+}
+
+// sortsKeys reports whether the keys of the mapping at item are sorted: if
+// SortPaths isn't empty, whether its path matches one of SortPaths, and
+// otherwise whether SortKeys is set and SortDepth allows its depth.
+func sortsKeys(item *queueItem, opts *Options) bool {
+	if len(opts.SortPaths) > 0 {
+		return matchesAny(opts.SortPaths, item.Path)
+	}
+	return opts.SortKeys && (opts.SortDepth <= 0 || item.Indent <= opts.SortDepth)
+}
+
+// normalizeBlockStyle turns a folded scalar with more indented lines into a
+// literal one. The encoder writes a blank line before every more indented
+// line of a folded scalar, which adds a line break to its value, while a
+// literal scalar keeps the relative indentation of its lines as it is.
+func normalizeBlockStyle(node *yaml.Node) {
+	if node.Kind&yaml.ScalarNode == 0 || node.Style&yaml.FoldedStyle == 0 {
+		return
+	}
+	for _, line := range strings.Split(node.Value, "\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			node.Style = node.Style ^ yaml.FoldedStyle | yaml.LiteralStyle
+			return
+		}
+	}
+}
+
+// priorityKeys returns the keys that come first in a mapping at path p. If
+// several patterns of keyOrder match, the lexically first one wins.
+func priorityKeys(keyOrder map[string][]string, p []string) []string {
+	patterns := sortedPatterns(keyOrder)
+	if pattern, ok := firstMatch(patterns, p); ok {
+		return keyOrder[pattern]
+	}
+	return nil
+}
+
+// allowedKeys returns the keys allowed in a mapping at path p, or nil if all
+// keys are. If several patterns of allowed match, the lexically first one
+// wins.
+func allowedKeys(allowed map[string][]string, p []string) map[string]bool {
+	patterns := sortedPatterns(allowed)
+	pattern, ok := firstMatch(patterns, p)
+	if !ok {
+		return nil
+	}
+	keys := map[string]bool{}
+	for _, key := range allowed[pattern] {
+		keys[key] = true
+	}
+	return keys
+}
+
+// dropKeys returns the tuples whose keys are allowed. With Debug, the path of
+// every dropped key is printed.
+func dropKeys(tuples []tupleItem, allowed map[string]bool, p []string, opts *Options) []tupleItem {
+	kept := []tupleItem{}
+	for _, tuple := range tuples {
+		if allowed[tuple.Key.Value] {
+			kept = append(kept, tuple)
+		} else if opts.Debug {
+			printDropped(opts, append(append([]string{}, p...), tuple.Key.Value))
+		}
+	}
+	return kept
+}
+
+// sortedPatterns returns the patterns that m maps, in lexical order, so that
+// firstMatch finds the lexically first one that matches.
+func sortedPatterns[V any](m map[string]V) []string {
+	patterns := make([]string, 0, len(m))
+	for pattern := range m {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// firstMatch returns the first of patterns that matches path p.
+func firstMatch(patterns []string, p []string) (string, bool) {
+	for _, pattern := range patterns {
+		if matchPath(pattern, p) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchesAny reports whether one of patterns matches path p.
+func matchesAny(patterns []string, p []string) bool {
+	_, ok := firstMatch(patterns, p)
+	return ok
+}
+
+// valueTransforms are the transforms that Options.ValueTransforms and
+// Options.KeyTransforms can name.
+var valueTransforms = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  func(s string) string { return strings.Trim(s, " ") },
+}
+
+// isLabels reports whether a mapping at path p is sorted by
+// SortLabelsByName.
+func isLabels(p []string) bool {
+	return matchPath("**.metadata.labels", p) || matchPath("**.metadata.annotations", p)
+}
+
+// lessKey orders mapping keys alphabetically, or in reverse with descending,
+// except that keys listed in order come first, in the order given. With
+// byName, keys are first compared by labelName. Keys with the same text, such
+// as the integer 1 and the string "1", are ordered by their tags.
+func lessKey(a *yaml.Node, b *yaml.Node, order []string, byName bool, descending bool) bool {
+	rank_a, rank_b := len(order), len(order)
+	for index, key := range order {
+		if key == a.Value {
+			rank_a = index
+		}
+		if key == b.Value {
+			rank_b = index
+		}
+	}
+	if rank_a != rank_b {
+		return rank_a < rank_b
+	}
+	if descending {
+		a, b = b, a
+	}
+	if byName {
+		if name_a, name_b := labelName(a.Value), labelName(b.Value); name_a != name_b {
+			return name_a < name_b
+		}
+	}
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.ShortTag() < b.ShortTag()
+}
+
+// labelName returns the lower-case name of a label or annotation key after
+// its last "/", followed by its prefix before that, so that keys sort by
+// name and then by prefix.
+func labelName(key string) string {
+	key = strings.ToLower(key)
+	i := strings.LastIndex(key, "/")
+	return key[i+1:] + "\x00" + key[:i+1]
+}
+
+// normalizeScalar rewrites the value of a scalar that isn't a mapping key.
+// Nulls are written in NullStyle, where empty nulls are written as null
+// until emptyNulls empties them for encoding. With NormalizeFloats, floats are written
+// in canonical form. Strings at paths matching a pattern of ValueTransforms
+// are transformed, where the lexically first matching pattern wins. With
+// TrimStrings, spaces around strings are removed, except in block scalars
+// and in strings that consist of spaces only.
+func normalizeScalar(item *queueItem, opts *Options) {
+	node := item.Node
+	if item.Key || node.Kind&yaml.ScalarNode == 0 {
+		return
+	}
+	if node.ShortTag() == "!!null" && node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) == 0 {
+		if value, ok := nullStyles[opts.NullStyle]; ok {
+			node.Value = value
+		}
+	}
+	if opts.NormalizeFloats && node.ShortTag() == "!!float" {
+		node.Value = canonicalFloat(node.Value)
+	}
+	if node.ShortTag() != "!!str" {
+		return
+	}
+	patterns := sortedPatterns(opts.ValueTransforms)
+	if pattern, ok := firstMatch(patterns, item.Path); ok {
+		node.Value = valueTransforms[opts.ValueTransforms[pattern]](node.Value)
+	}
+	if opts.TrimStrings && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+		if trimmed := strings.Trim(node.Value, " "); trimmed != "" {
+			node.Value = trimmed
+		}
+	}
+}
+
+// canonicalFloat returns the shortest decimal form of the float s with at
+// least one digit on both sides of the point, such as 0.5 for .5 and 1000.0
+// for 1e3, or an exponent form such as 1.0e+30 for very large and very small
+// numbers. Infinities, NaN and text that doesn't parse are returned as they
+// are.
+func canonicalFloat(s string) string {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return s
+	}
+	r := strconv.FormatFloat(f, 'f', -1, 64)
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		r = strconv.FormatFloat(f, 'e', -1, 64)
+	}
+	if !strings.ContainsAny(r, ".e") {
+		return r + ".0"
+	}
+	if i := strings.Index(r, "e"); i >= 0 && !strings.Contains(r[:i], ".") {
+		return r[:i] + ".0" + r[i:]
+	}
+	return r
+}
+
+// normalizeStyle strips quoting and flow style from a node. Mapping keys
+// are only unquoted if they are plain keys. With KeepFlow, flow style is
+// kept, except on sequences of more than KeepFlowMax items when KeepFlowMax
+// is positive. Flow sequences of at most FlowFoldThreshold items are kept
+// when FlowFoldThreshold is positive.
+func normalizeStyle(item *queueItem, opts *Options) {
+	unquote := unquotable(item.Node) && (!item.Key || plainKey(item.Node.Value))
+	if !item.Key && matchesAny(opts.PreserveQuotes, item.Path) {
+		unquote = false
+	}
+	if item.Node.Style&yaml.SingleQuotedStyle > 0 && unquote {
+		item.Node.Style = item.Node.Style ^ yaml.SingleQuotedStyle
+	}
+	if item.Node.Style&yaml.DoubleQuotedStyle > 0 && unquote {
+		item.Node.Style = item.Node.Style ^ yaml.DoubleQuotedStyle
+	}
+	if item.Node.Style&yaml.FlowStyle > 0 && !keepFlowStyle(item.Node, opts) {
+		item.Node.Style = item.Node.Style ^ yaml.FlowStyle
+	}
+}
+
+// normalizeSeqStyle turns a flow sequence of more than FlowFoldThreshold
+// items into block style, and renders a sequence in the style given by
+// SeqStyle.
+func normalizeSeqStyle(node *yaml.Node, opts *Options) {
+	if node.Kind&yaml.SequenceNode == 0 {
+		return
+	}
+	if opts.FlowFoldThreshold > 0 && len(node.Content) > opts.FlowFoldThreshold {
+		node.Style &^= yaml.FlowStyle
+	}
+	switch opts.SeqStyle {
+	case "block":
+		node.Style &^= yaml.FlowStyle
+	case "flow":
+		node.Style |= yaml.FlowStyle
+	}
+}
+
+func keepFlowStyle(node *yaml.Node, opts *Options) bool {
+	if node.Kind&yaml.SequenceNode > 0 && opts.FlowFoldThreshold > 0 {
+		return len(node.Content) <= opts.FlowFoldThreshold
+	}
+	if !opts.KeepFlow {
+		return false
+	}
+	if node.Kind&yaml.SequenceNode > 0 && opts.KeepFlowMax > 0 {
+		return len(node.Content) <= opts.KeepFlowMax
+	}
+	return true
+}
+
+// nullStyles maps the values of Options.NullStyle to the text of nulls.
+var nullStyles = map[string]string{
+	"null":  "null",
+	"tilde": "~",
+	"empty": "null",
+}
+
+// emptyNulls empties the text of the null values of the block mappings of
+// node and the nodes below it, and returns a function that puts it back.
+// Elsewhere, nulls in NullStyle "empty" stay null: an empty item of a flow
+// collection decodes as an empty string, and an empty item of a block
+// sequence is easy to miss.
+func emptyNulls(node *yaml.Node) func() {
+	nulls := []*yaml.Node{}
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Style&yaml.FlowStyle != 0 {
+			return
+		}
+		if node.Kind&yaml.MappingNode > 0 {
+			for i := 1; i < len(node.Content); i += 2 {
+				value := node.Content[i]
+				if value.Kind&yaml.ScalarNode > 0 && value.ShortTag() == "!!null" && value.Value == "null" &&
+					value.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) == 0 {
+					value.Value = ""
+					nulls = append(nulls, value)
+				}
+			}
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+	return func() {
+		for _, value := range nulls {
+			value.Value = "null"
+		}
+	}
+}
+
+// yaml11Bools lists the plain scalars that YAML 1.1 parsers read as booleans
+// even though YAML 1.2 resolves them to strings.
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true,
+	"off": true, "Off": true, "OFF": true,
+}
+
+// unquotable reports whether a quoted scalar resolves to the same tag once
+// its quotes are removed, so that stripping them doesn't change its type.
+func unquotable(node *yaml.Node) bool {
+	plain := &yaml.Node{Kind: yaml.ScalarNode, Value: node.Value}
+	if plain.ShortTag() != node.ShortTag() {
+		return false
+	}
+	return !yaml11Bools[node.Value]
+}
+
+// plainKey reports whether s can be written as a plain mapping key that
+// every YAML parser reads back as s: it doesn't start with an indicator or a
+// space, doesn't end with a space and contains no ": ", " #", tabs or line
+// breaks.
+func plainKey(s string) bool {
+	if s == "" || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` ") {
+		return false
+	}
+	if strings.HasSuffix(s, " ") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	return !strings.Contains(s, ": ") && !strings.Contains(s, " #") && !strings.ContainsAny(s, "\t\r\n")
+}
+
+// mapKey identifies a mapping key by its tag and text, so that keys such as
+// the integer 1 and the string "1" are told apart.
+type mapKey struct {
+	Tag   string
+	Value string
+}
+
+func keyOf(node *yaml.Node) mapKey {
+	return mapKey{Tag: node.ShortTag(), Value: node.Value}
+}
+
+// stringKey returns the mapKey of the string key s.
+func stringKey(s string) mapKey {
+	return mapKey{Tag: "!!str", Value: s}
+}
+
+func mapping(s []*yaml.Node) (map[mapKey]*yaml.Node, error) {
+	i := 0
+	r := make(map[mapKey]*yaml.Node)
+	if len(s)%2 != 0 {
+		return r, errors.New("Mapping expected even number of nodes")
+	}
+	for i < len(s) {
+		r[keyOf(s[i])] = s[i+1]
+		i += 2
+	}
+	return r, nil
+}
+
+func tuples(s []*yaml.Node) ([]tupleItem, error) {
+	i := 0
+	r := []tupleItem{}
+	if len(s)%2 != 0 {
+		return r, errors.New("Tuples expected even number of nodes")
+	}
+	for i < len(s) {
+		r = append(r, tupleItem{Key: s[i], Value: s[i+1]})
+		i += 2
+	}
+	return r, nil
+}
+
+func contents(s []tupleItem) []*yaml.Node {
+	r := []*yaml.Node{}
+	for _, i := range s {
+		r = append(r, i.Key)
+		r = append(r, i.Value)
+	}
+	return r
+}
@@ -0,0 +1,30 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNormalizeOddMapping(t *testing.T) {
+	var doc yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte("a:\n  b: 1\n  c: 2\n"), &doc))
+	inner := doc.Content[0].Content[1]
+	inner.Content = inner.Content[:3]
+
+	opts := DefaultOptions()
+	err := normalize(queueItem{Node: &doc, Path: []string{}}, &opts)
+	assert.EqualError(t, err, "line 2: Tuples expected even number of nodes")
+}
+
+func TestMappingTypedKeys(t *testing.T) {
+	var doc yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte("1: int\n\"1\": string\n"), &doc))
+
+	m, err := mapping(doc.Content[0].Content)
+	assert.NoError(t, err)
+	assert.Len(t, m, 2)
+	assert.Equal(t, "int", m[mapKey{Tag: "!!int", Value: "1"}].Value)
+	assert.Equal(t, "string", m[stringKey("1")].Value)
+}
@@ -0,0 +1,74 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkSemantics returns an error if the YAML streams in and out don't
+// decode to the same documents. The order of the documents doesn't matter,
+// since Format sorts them. With dedup, the number of equal documents doesn't
+// matter either.
+func checkSemantics(in []byte, out []byte, dedup bool) error {
+	before, err := decodeAll(in)
+	if err != nil {
+		return err
+	}
+	after, err := decodeAll(out)
+	if err != nil {
+		return fmt.Errorf("Formatted output doesn't decode: %v", err)
+	}
+
+	if dedup {
+		before, after = distinct(before), distinct(after)
+	}
+	if len(before) != len(after) {
+		return fmt.Errorf("Formatting changes the number of documents from %d to %d", len(before), len(after))
+	}
+	remaining := map[string]int{}
+	for _, doc := range after {
+		remaining[doc]++
+	}
+	for i, doc := range before {
+		if remaining[doc] == 0 {
+			return fmt.Errorf("Formatting changes the decoded content of document %d", i+1)
+		}
+		remaining[doc]--
+	}
+	return nil
+}
+
+// distinct returns docs without repetitions.
+func distinct(docs []string) []string {
+	seen := map[string]bool{}
+	kept := []string{}
+	for _, doc := range docs {
+		if !seen[doc] {
+			seen[doc] = true
+			kept = append(kept, doc)
+		}
+	}
+	return kept
+}
+
+// decodeAll decodes every document of a YAML stream into a generic value
+// and returns the values in a printed form that is equal for equal values.
+func decodeAll(b []byte) ([]string, error) {
+	docs := []string{}
+	d := yaml.NewDecoder(bytes.NewReader(withoutVersion(b)))
+	for {
+		var v interface{}
+		err := decode(d, &v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, fmt.Sprintf("%#v", v))
+	}
+	return docs, nil
+}
@@ -0,0 +1,32 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSemantics(t *testing.T) {
+	assert.NoError(t, checkSemantics([]byte("a: 1\n---\nb: 2\n"), []byte("b: 2\n---\na: 1\n"), false))
+	assert.EqualError(t, checkSemantics([]byte("a: \"00\"\n"), []byte("a: 00\n"), false),
+		"Formatting changes the decoded content of document 1")
+	assert.EqualError(t, checkSemantics([]byte("a: 1\n---\na: 1\n"), []byte("a: 1\n"), false),
+		"Formatting changes the number of documents from 2 to 1")
+	assert.NoError(t, checkSemantics([]byte("a: 1\n---\na: 1\n"), []byte("a: 1\n"), true))
+}
+
+func TestFormatCheckSemantics(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CheckSemantics = true
+
+	var out bytes.Buffer
+	assert.NoError(t, Format(strings.NewReader("b: \"00\"\na: '1.0'\n"), &out, opts))
+	assert.Equal(t, "a: '1.0'\nb: \"00\"\n", out.String())
+
+	opts.PruneNull = true
+	out.Reset()
+	assert.Error(t, Format(strings.NewReader("a: null\nb: 1\n"), &out, opts))
+	assert.Equal(t, "", out.String())
+}
@@ -0,0 +1,120 @@
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setValues applies Options.Set to doc, in the lexical order of the paths.
+func setValues(doc *yaml.Node, set map[string]string) error {
+	paths := []string{}
+	for p := range set {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := setValue(doc, strings.Split(p, "."), set[p]); err != nil {
+			return fmt.Errorf("Cannot set %s: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// setValue sets the scalar at path p in doc to value. Missing mapping keys
+// along p are added, with empty mappings for the intermediate ones. Keys are
+// matched as in lookup, so by tag as well as text and through merge keys. A
+// value that is only merged in, or that is an alias, is replaced by a copy
+// before it is changed, so that the other mappings merging it and the other
+// aliases of its anchor are left as they are. The tag of the scalar is
+// resolved from value, as for a plain scalar.
+func setValue(doc *yaml.Node, p []string, value string) error {
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	node := doc.Content[0]
+	for i, key := range p {
+		slot := -1
+		switch {
+		case node.Kind&yaml.MappingNode > 0:
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if keyOf(node.Content[j]) == stringKey(key) {
+					slot = j + 1
+				}
+			}
+			if slot >= 0 {
+				break
+			}
+			merged, err := lookup(node, key)
+			if err != nil {
+				return err
+			}
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if merged != nil {
+				child = merged
+			} else if i == len(p)-1 {
+				child = &yaml.Node{Kind: yaml.ScalarNode}
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+			slot = len(node.Content) - 1
+			if merged != nil {
+				if err := copyOnWrite(node, slot); err != nil {
+					return err
+				}
+			}
+		case node.Kind&yaml.SequenceNode > 0:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return fmt.Errorf("%s is not an index of the sequence at %s", key, pathName(p[:i]))
+			}
+			slot = index
+		default:
+			return fmt.Errorf("%s is not a mapping or sequence", pathName(p[:i]))
+		}
+		if node.Content[slot].Kind&yaml.AliasNode > 0 {
+			if err := copyOnWrite(node, slot); err != nil {
+				return err
+			}
+		}
+		node = node.Content[slot]
+	}
+
+	if node.Kind&yaml.ScalarNode == 0 {
+		return fmt.Errorf("%s is not a scalar", pathName(p))
+	}
+	node.Value = value
+	node.Tag = ""
+	node.Style = 0
+	return nil
+}
+
+// copyOnWrite replaces the child of node at index i by a copy without
+// aliases or anchors.
+func copyOnWrite(node *yaml.Node, i int) error {
+	c, err := copyNode(node.Content[i], map[*yaml.Node]bool{})
+	if err != nil {
+		return err
+	}
+	dropAnchors(c)
+	node.Content[i] = c
+	return nil
+}
+
+// dropAnchors removes the anchors of node and the nodes below it.
+func dropAnchors(node *yaml.Node) {
+	node.Anchor = ""
+	for _, child := range node.Content {
+		dropAnchors(child)
+	}
+}
+
+// pathName returns the dotted path p for messages.
+func pathName(p []string) string {
+	if len(p) == 0 {
+		return "the document"
+	}
+	return strings.Join(p, ".")
+}
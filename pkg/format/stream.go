@@ -0,0 +1,140 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// formatStream formats the documents read from r for Format when they
+// aren't sorted, merged, deduplicated or checked as a whole. Every document
+// is decoded, formatted and written to w before the next one is decoded, so
+// that neither the input, nor the decoded documents, nor the output have to
+// be kept in memory. Only the lines of the document being formatted and of
+// the one before it are kept, since formatting looks at the source of a
+// document for its comments and with MinimalDiff. Without AllowPartial, the
+// documents before one that fails to format have already been written.
+func formatStream(r io.Reader, w io.Writer, opts *Options) error {
+	source := newLineReader(r)
+	d := yaml.NewDecoder(source)
+	position := 0
+	written := 0
+	failed := []error{}
+	var err error
+	var prev *yaml.Node
+	for {
+		doc := &yaml.Node{}
+		if err = decode(d, doc); err != nil {
+			break
+		}
+		if prev != nil {
+			source.forget(prev.Line)
+		}
+		prev = doc
+		position++
+		reportIndent(doc, position, opts)
+		spans := map[*yaml.Node]string{}
+		if opts.MinimalDiff {
+			sourceSpans(doc, source.lines, position == 1, spans)
+		}
+		if e := formatAt(doc, position, source.lines, opts); e != nil {
+			if !opts.AllowPartial {
+				return e
+			}
+			failed = append(failed, e)
+			continue
+		}
+		if e := encodeDocument(w, doc, written == 0, source.lines, spans, opts); e != nil {
+			return e
+		}
+		written++
+	}
+
+	if err == io.EOF {
+		err = nil
+	} else {
+		err = syntaxError(err, position+1, source.lines, opts)
+	}
+	if len(failed) > 0 {
+		err = errors.Join(append(failed, err)...)
+	}
+	return err
+}
+
+// lineReader passes a stream on to the decoder one line at a time and keeps
+// the lines it has passed on, split at line feeds as in Format. The %YAML
+// directive before the first document is passed on as an empty line, as
+// withoutVersion does.
+type lineReader struct {
+	r     *bufio.Reader
+	lines []string
+	// next is what is left of the last line to pass on.
+	next   string
+	header bool
+	err    error
+	// forgotten lines at the start of lines have been emptied, and the
+	// decoder counts counted lines in them.
+	forgotten int
+	counted   int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReader(r), header: true}
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	for l.next == "" {
+		if l.err != nil {
+			return 0, l.err
+		}
+		line, err := l.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		l.err = err
+		text := strings.TrimSuffix(line, "\n")
+		l.lines = append(l.lines, text)
+		if l.header {
+			trimmed := strings.TrimSpace(text)
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(text, "%") {
+				l.header = false
+			} else if strings.HasPrefix(text, "%YAML") {
+				line = line[len(text):]
+			}
+		}
+		l.next = line
+	}
+	n := copy(p, l.next)
+	l.next = l.next[n:]
+	return n, nil
+}
+
+// forget empties the lines before the one that holds the given line as the
+// decoder counts them. The decoder also breaks lines at a carriage return
+// that isn't followed by a line feed, and at the characters NEL, LS and PS.
+func (l *lineReader) forget(line int) {
+	for l.forgotten < len(l.lines)-1 {
+		text := l.lines[l.forgotten]
+		breaks := strings.Count(strings.TrimSuffix(text, "\r"), "\r")
+		for _, b := range []string{"\u0085", "\u2028", "\u2029"} {
+			breaks += strings.Count(text, b)
+		}
+		if l.counted+1+breaks >= line {
+			return
+		}
+		l.counted += 1 + breaks
+		l.lines[l.forgotten] = ""
+		l.forgotten++
+	}
+}
+
+// isUTF16 reports whether source starts with a UTF-16 byte order mark. The
+// decoder then reads source as UTF-16, so its lines can't be told apart in
+// the bytes of source.
+func isUTF16(source []byte) bool {
+	return bytes.HasPrefix(source, []byte("\xff\xfe")) || bytes.HasPrefix(source, []byte("\xfe\xff"))
+}
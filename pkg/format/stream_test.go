@@ -0,0 +1,146 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// heapWriter discards what is written and records the largest live heap
+// after a garbage collection every 2000 writes.
+type heapWriter struct {
+	writes  int
+	maxHeap uint64
+}
+
+func (w *heapWriter) Write(p []byte) (int, error) {
+	if w.writes%2000 == 0 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > w.maxHeap {
+			w.maxHeap = stats.HeapAlloc
+		}
+	}
+	w.writes++
+	return len(p), nil
+}
+
+func largeStream(n int) string {
+	var in strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&in, "---\nkind: ConfigMap\nmetadata:\n  name: config-%05d\ndata:\n  b: \"%d\"\n  a: x\n", i, i)
+	}
+	return in.String()
+}
+
+func TestFormatStreamMemory(t *testing.T) {
+	in := largeStream(10000)
+
+	sorted := &heapWriter{}
+	assert.NoError(t, Format(strings.NewReader(in), sorted, DefaultOptions()))
+
+	opts := DefaultOptions()
+	opts.SortDocuments = false
+	streamed := &heapWriter{}
+	assert.NoError(t, Format(strings.NewReader(in), streamed, opts))
+
+	t.Logf("largest heap: %d bytes sorted, %d bytes streamed", sorted.maxHeap, streamed.maxHeap)
+	assert.True(t, streamed.maxHeap*2 < sorted.maxHeap,
+		"largest heap of %d bytes streamed and %d bytes sorted", streamed.maxHeap, sorted.maxHeap)
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r    io.Reader
+	read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += n
+	return n, err
+}
+
+// firstWriter records how many bytes of the input had been read when it
+// was first written to.
+type firstWriter struct {
+	input *countingReader
+	read  int
+}
+
+func (w *firstWriter) Write(p []byte) (int, error) {
+	if w.read == 0 {
+		w.read = w.input.read
+	}
+	return len(p), nil
+}
+
+func TestFormatStreamWrites(t *testing.T) {
+	in := largeStream(1000)
+	opts := DefaultOptions()
+	opts.SortDocuments = false
+	input := &countingReader{r: strings.NewReader(in)}
+	out := &firstWriter{input: input}
+	assert.NoError(t, Format(input, out, opts))
+	assert.True(t, out.read > 0 && out.read < len(in)/10,
+		"first write after reading %d of %d bytes", out.read, len(in))
+}
+
+func TestFormatStreamError(t *testing.T) {
+	// The documents before a syntax error have already been written.
+	opts := DefaultOptions()
+	opts.SortDocuments = false
+	var out strings.Builder
+	assert.EqualError(t, Format(strings.NewReader("b: 1\na: 2\n---\nc: [\n"), &out, opts),
+		"document 2: yaml: line 4: did not find expected node content")
+	assert.Equal(t, "a: 2\nb: 1\n", out.String())
+
+	out.Reset()
+	opts.AllowPartial = true
+	assert.Error(t, Format(strings.NewReader("b: 1\na: 2\n---\nc: [\n"), &out, opts))
+	assert.Equal(t, "a: 2\nb: 1\n", out.String())
+
+	// A document after a "..." marker needs a "---" marker.
+	out.Reset()
+	opts.AllowPartial = false
+	assert.EqualError(t, Format(strings.NewReader("b: 1\n...\na: 2\n"), &out, opts),
+		"document 2: yaml: line 2: did not find expected <document start>")
+	assert.Equal(t, "b: 1\n", out.String())
+}
+
+func TestFormatStreamLineBreaks(t *testing.T) {
+	utf16 := "\xff\xfe"
+	for _, c := range []byte("b: 1\na: 2\n---\nc: 3\n") {
+		utf16 += string([]byte{c, 0})
+	}
+	opts := DefaultOptions()
+	opts.SortDocuments = false
+	for _, in := range []string{
+		"b: 1\r\na: 2\r\n---\r\nc: 3\r\n",
+		"b: 1\ra: 2\r---\rc: 3\r",
+		"b: 1\u2028a: 2\u2029---\u0085c: 3\n",
+		"b: 1\na: 2\n...\n---\nc: 3\n...\n",
+		utf16,
+	} {
+		var out strings.Builder
+		assert.NoError(t, Format(strings.NewReader(in), &out, opts), in)
+		assert.Equal(t, "a: 2\nb: 1\n---\nc: 3\n", out.String(), in)
+	}
+}
+
+func BenchmarkFormatStream(b *testing.B) {
+	in := largeStream(1000)
+	opts := DefaultOptions()
+	opts.SortDocuments = false
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := Format(strings.NewReader(in), &heapWriter{writes: 1}, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"errors"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+func traverse(node *yaml.Node, keys ...string) (*yaml.Node, error) {
+	i := 0
+	for i < len(keys) {
+		if node.Kind&yaml.DocumentNode > 0 {
+			if len(node.Content) != 1 {
+				return nil, errors.New("Expected one child for DocumentNode")
+			}
+			node = node.Content[0]
+		} else if node.Kind&yaml.SequenceNode > 0 {
+			index, err := strconv.Atoi(keys[i])
+			if err != nil {
+				return nil, errors.New("Traversed to sequence node but got no index")
+			}
+			if index < 0 || index >= len(node.Content) {
+				return nil, errors.New("Traversed to sequence node but index out of range")
+			}
+			node = node.Content[index]
+			i++
+		} else if node.Kind&yaml.MappingNode > 0 {
+			value, err := lookup(node, keys[i])
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				return nil, errors.New("Traversed to mapping node but key not in mapping")
+			}
+			node = value
+			i++
+		} else if node.Kind&yaml.ScalarNode > 0 {
+			return nil, errors.New("Traversed to ScalarNode, but not finished yet")
+		} else if node.Kind&yaml.AliasNode > 0 {
+			node = node.Alias
+		}
+	}
+	return resolve(node), nil
+}
+
+// lookup returns the value of the string key in a mapping node, or nil if
+// there is none. Keys inherited through "<<" merge keys are found too, with
+// the mapping's own keys taking precedence over merged ones, and earlier
+// merged mappings over later ones.
+func lookup(node *yaml.Node, key string) (*yaml.Node, error) {
+	mapping, err := mapping(node.Content)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := mapping[stringKey(key)]; ok {
+		return value, nil
+	}
+	merges, _ := tuples(node.Content)
+	for _, tuple := range merges {
+		if !isMergeKey(tuple.Key) {
+			continue
+		}
+		sources := []*yaml.Node{resolve(tuple.Value)}
+		if sources[0].Kind&yaml.SequenceNode > 0 {
+			sources = sources[0].Content
+		}
+		for _, source := range sources {
+			source = resolve(source)
+			if source.Kind&yaml.MappingNode == 0 {
+				continue
+			}
+			value, err := lookup(source, key)
+			if err != nil || value != nil {
+				return value, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// resolve follows aliases to the node they refer to.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind&yaml.AliasNode > 0 {
+		node = node.Alias
+	}
+	return node
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+func TestStartProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cpu := filepath.Join(dir, "cpu.prof")
+	mem := filepath.Join(dir, "mem.prof")
+	stop, err := startProfiles(cpu, mem)
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	assert.NoError(t, format.Format(strings.NewReader("b: 1\na: 2\n"), &out, format.DefaultOptions()))
+	assert.NoError(t, stop())
+
+	for _, f := range []string{cpu, mem} {
+		info, err := os.Stat(f)
+		assert.NoError(t, err)
+		assert.NotZero(t, info.Size(), f)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+// fileReport describes the formatting result of one file in a -report=json
+// report.
+type fileReport struct {
+	Path    string `json:"path"`
+	Changed bool   `json:"changed"`
+	Content string `json:"content"`
+}
+
+// reportFiles formats the files without writing them and writes a JSON
+// array to w with a fileReport for each file. It reports whether any file
+// would be reformatted.
+func reportFiles(files []string, w io.Writer, opts format.Options) (bool, error) {
+	reports := []fileReport{}
+	anyChanged := false
+	for _, f := range files {
+		in, _, err := readInput(f)
+		if err != nil {
+			return false, err
+		}
+		opts.Warn = warner(f)
+		var out bytes.Buffer
+		if e := format.Format(bytes.NewReader(in), &out, opts); e != nil {
+			return false, fmt.Errorf("Failed formatting YAML stream: %w%s", fileError(f, e), templateHint(in))
+		}
+		changed := !bytes.Equal(in, out.Bytes())
+		anyChanged = anyChanged || changed
+		reports = append(reports, fileReport{Path: displayName(f), Changed: changed, Content: out.String()})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return anyChanged, enc.Encode(reports)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+func TestReportFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	messy := filepath.Join(dir, "messy.yaml")
+	tidy := filepath.Join(dir, "tidy.yaml")
+	assert.NoError(t, ioutil.WriteFile(messy, []byte("b:   1\na: 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(tidy, []byte("a: 2\nb: 1\n"), 0644))
+
+	var out bytes.Buffer
+	changed, err := reportFiles([]string{messy, tidy}, &out, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	var reports []fileReport
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &reports))
+	assert.Equal(t, []fileReport{
+		{Path: messy, Changed: true, Content: "a: 2\nb: 1\n"},
+		{Path: tidy, Changed: false, Content: "a: 2\nb: 1\n"},
+	}, reports)
+
+	content, err := ioutil.ReadFile(messy)
+	assert.NoError(t, err)
+	assert.Equal(t, "b:   1\na: 2\n", string(content))
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+// streamFile formats the content in of the file f and writes the documents
+// to the file output, or to stdout if output is empty, as they are encoded
+// instead of collecting the whole output first. It reports whether the
+// formatted content differs from in. With compress, the output file is
+// gzip-compressed.
+func streamFile(f string, in []byte, output string, compress bool, opts format.Options) (bool, error) {
+	w := stdout
+	var o *outputWriter
+	var gz *gzip.Writer
+	if output != "" {
+		o = &outputWriter{name: output}
+		w = o
+		if compress {
+			gz = gzip.NewWriter(o)
+			w = gz
+		}
+	}
+
+	changed, e := formatTo(in, w, opts)
+	if e != nil {
+		if o != nil && o.f != nil && opts.AllowPartial {
+			// Keep the documents written with AllowPartial, readable.
+			if gz != nil {
+				gz.Close()
+			}
+			o.commit()
+		} else if o != nil {
+			o.discard()
+		}
+		return false, fmt.Errorf("Failed formatting YAML stream: %w%s", fileError(f, e), templateHint(in))
+	}
+	if gz != nil {
+		if e := gz.Close(); e != nil {
+			o.discard()
+			return changed, fmt.Errorf("Cannot write output: %v", e)
+		}
+	}
+	if o != nil {
+		// Create the output file even if the formatted stream is empty.
+		if e := o.commit(); e != nil {
+			return changed, fmt.Errorf("Cannot write output: %v", e)
+		}
+	}
+	return changed, nil
+}
+
+// formatTo formats in and writes the result to w. It reports whether the
+// result differs from in without keeping a copy of it.
+func formatTo(in []byte, w io.Writer, opts format.Options) (bool, error) {
+	c := &changeWriter{w: w, original: in}
+	if err := format.Format(bytes.NewReader(in), c, opts); err != nil {
+		return false, err
+	}
+	return c.changed || c.n != len(in), nil
+}
+
+// changeWriter passes writes on to w and records whether the written bytes
+// differ from original.
+type changeWriter struct {
+	w        io.Writer
+	original []byte
+	n        int
+	changed  bool
+}
+
+func (c *changeWriter) Write(p []byte) (int, error) {
+	end := c.n + len(p)
+	if end > len(c.original) || !bytes.Equal(c.original[c.n:end], p) {
+		c.changed = true
+	}
+	if end <= len(c.original) {
+		c.n = end
+	}
+	return c.w.Write(p)
+}
+
+// stdout is where the output of files without an output file is written.
+var stdout io.Writer = os.Stdout
+
+// separatedWriter writes the output of several files to w as one stream,
+// with a "---" separator between the files unless a file starts with one. A
+// file that starts with directives is preceded by a "..." marker instead,
+// unless the file before it ends with one, since directives have to follow
+// the end of a document. Files without output get no separator. The start of
+// the output of a file is held back until it shows which separator it needs.
+type separatedWriter struct {
+	w io.Writer
+	// written is set once a file has had output, and ended if the last
+	// such file ends with a "..." marker.
+	written bool
+	ended   bool
+	// start is the output of the current file that is held back, until
+	// started.
+	start   []byte
+	started bool
+	// last holds the last bytes written of the current file.
+	last []byte
+}
+
+func (s *separatedWriter) Write(p []byte) (int, error) {
+	if !s.started {
+		s.start = append(s.start, p...)
+		if len(s.start) < 4 && bytes.HasPrefix([]byte("---"), s.start) {
+			return len(p), nil
+		}
+		return len(p), s.flush()
+	}
+	s.keep(p)
+	return s.w.Write(p)
+}
+
+// next ends the output of the current file.
+func (s *separatedWriter) next() error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if s.started {
+		s.written = true
+		s.ended = bytes.Equal(s.last, []byte("...\n")) || bytes.HasSuffix(s.last, []byte("\n...\n"))
+	}
+	s.started = false
+	s.last = nil
+	return nil
+}
+
+// flush writes the separator that the held back output needs, and the
+// output.
+func (s *separatedWriter) flush() error {
+	if len(s.start) == 0 {
+		return nil
+	}
+	separator := "---\n"
+	if bytes.HasPrefix(s.start, []byte("%")) {
+		separator = "...\n"
+		if s.ended {
+			separator = ""
+		}
+	} else if bytes.HasPrefix(s.start, []byte("---\n")) || bytes.HasPrefix(s.start, []byte("--- ")) {
+		// A stream that starts with a document marker needs no separator.
+		separator = ""
+	}
+	if s.written && separator != "" {
+		if _, err := io.WriteString(s.w, separator); err != nil {
+			return err
+		}
+	}
+	start := s.start
+	s.start = nil
+	s.started = true
+	s.keep(start)
+	_, err := s.w.Write(start)
+	return err
+}
+
+// keep records the last bytes of p in last.
+func (s *separatedWriter) keep(p []byte) {
+	s.last = append(s.last, p...)
+	if len(s.last) > 5 {
+		s.last = append([]byte(nil), s.last[len(s.last)-5:]...)
+	}
+}
+
+// outputWriter writes the file name through a temporary file next to it,
+// which it creates when it is first written to and which replaces name once
+// committed. Formatting that fails thus leaves no file behind, nor changes
+// an existing one. A name that exists but isn't a regular file, such as
+// /dev/stdout, is written directly.
+type outputWriter struct {
+	name string
+	f    *os.File
+	temp bool
+}
+
+func (o *outputWriter) open() error {
+	if o.f != nil {
+		return nil
+	}
+	info, err := os.Stat(o.name)
+	if err == nil && !info.Mode().IsRegular() {
+		f, err := os.OpenFile(o.name, os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		o.f = f
+		return nil
+	}
+	mode := os.FileMode(0644)
+	if err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(o.name), "."+filepath.Base(o.name)+".")
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	o.f, o.temp = f, true
+	return nil
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	if err := o.open(); err != nil {
+		return 0, err
+	}
+	return o.f.Write(p)
+}
+
+// commit replaces the file name by what has been written, which may be
+// nothing.
+func (o *outputWriter) commit() error {
+	if err := o.open(); err != nil {
+		return err
+	}
+	if err := o.f.Close(); err != nil {
+		o.remove()
+		return err
+	}
+	if !o.temp {
+		return nil
+	}
+	if err := os.Rename(o.f.Name(), o.name); err != nil {
+		o.remove()
+		return err
+	}
+	return nil
+}
+
+// discard drops what has been written.
+func (o *outputWriter) discard() {
+	if o.f != nil {
+		o.f.Close()
+		o.remove()
+	}
+}
+
+// remove removes the temporary file.
+func (o *outputWriter) remove() {
+	if o.temp {
+		os.Remove(o.f.Name())
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+// largestWriter records the size of the largest write.
+type largestWriter struct {
+	bytes.Buffer
+	largest int
+}
+
+func (w *largestWriter) Write(p []byte) (int, error) {
+	if len(p) > w.largest {
+		w.largest = len(p)
+	}
+	return w.Buffer.Write(p)
+}
+
+var errWrite = errors.New("cannot write")
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestFormatToStreams(t *testing.T) {
+	var in strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&in, "---\nkind: ConfigMap\nmetadata:\n  name: config-%04d\n", i)
+	}
+
+	unsorted := format.DefaultOptions()
+	unsorted.SortDocuments = false
+	for _, opts := range []format.Options{format.DefaultOptions(), unsorted} {
+		var out largestWriter
+		changed, err := formatTo([]byte(in.String()), &out, opts)
+		assert.NoError(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, in.String(), out.String())
+		assert.True(t, out.largest < out.Len()/100, "largest write %d of %d bytes", out.largest, out.Len())
+	}
+
+	// Unsorted documents reach the writer before the end of the input is
+	// decoded, so writing fails before the syntax error at the end is found.
+	broken := []byte(in.String() + "---\nc: [\n")
+	_, err := formatTo(broken, failingWriter{}, unsorted)
+	assert.True(t, errors.Is(err, errWrite), "%v", err)
+	_, err = formatTo(broken, failingWriter{}, format.DefaultOptions())
+	assert.EqualError(t, err, "document 1001: yaml: line 4002: did not find expected node content")
+}
+
+func TestFormatToChanged(t *testing.T) {
+	for in, expected := range map[string]bool{
+		"a: 1\nb: 2\n":   false,
+		"b: 2\na: 1\n":   true,
+		"a: 1\nb: 2":     true,
+		"a: 1\nb: 2\n\n": true,
+	} {
+		var out bytes.Buffer
+		changed, err := formatTo([]byte(in), &out, format.DefaultOptions())
+		assert.NoError(t, err)
+		assert.Equal(t, expected, changed, in)
+	}
+}
+
+func TestStreamFileError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out.yaml")
+	_, err = streamFile("in.yaml", []byte("a: [\n"), out, false, format.DefaultOptions())
+	assert.Error(t, err)
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+
+	// Without -allow-partial, an existing output file is left as it is,
+	// also when documents are formatted one at a time.
+	assert.NoError(t, ioutil.WriteFile(out, []byte("old\n"), 0644))
+	opts := format.DefaultOptions()
+	opts.SortDocuments = false
+	_, err = streamFile("in.yaml", []byte("a: 1\n---\nb: [\n"), out, false, opts)
+	assert.Error(t, err)
+	content, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "old\n", string(content))
+
+	changed, err := streamFile("in.yaml", []byte(""), out, false, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	content, err = ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(content))
+}
+
+func TestSeparatedWriter(t *testing.T) {
+	// The separator is chosen from the start of the output of a file even
+	// if it is written a byte at a time.
+	var out bytes.Buffer
+	s := &separatedWriter{w: &out}
+	for _, file := range []string{"a: 1\n", "", "---\nb: 1\n...\n", "%YAML 1.1\n---\nc: 1\n", "--- d\n"} {
+		for i := range file {
+			_, err := s.Write([]byte(file[i : i+1]))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, s.next())
+	}
+	assert.Equal(t, "a: 1\n---\nb: 1\n...\n%YAML 1.1\n---\nc: 1\n--- d\n", out.String())
+}
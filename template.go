@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+)
+
+// templateAction matches a Go template action such as {{ .Values.name }},
+// but not a ${{ ... }} expression of GitHub Actions, which is valid YAML.
+var templateAction = regexp.MustCompile(`(?m)(^|[^$])\{\{.*\}\}`)
+
+// isTemplate reports whether in contains Go template actions, as Helm charts
+// do. Formatting such a file would fail or break the template.
+func isTemplate(in []byte) bool {
+	return templateAction.Match(in)
+}
+
+// templateHint returns a note to append to the formatting error of in if in
+// is a template, and "" otherwise.
+func templateHint(in []byte) string {
+	if !isTemplate(in) {
+		return ""
+	}
+	return " (the file contains template directives, -template-safe skips such files)"
+}
+
+// withoutTemplates returns the files that aren't templates, and logs the
+// files that are skipped. Stdin is checked like the files.
+func withoutTemplates(files []string) ([]string, error) {
+	kept := []string{}
+	for _, f := range files {
+		in, _, err := readInput(f)
+		if err != nil {
+			return nil, err
+		}
+		if isTemplate(in) {
+			log.Printf("%s: skipped, contains template directives", displayName(f))
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, nil
+}
+
+// copyTemplate writes stdin unchanged to the file output, or to stdout if
+// output is empty, if it is a template, and reports whether it did. Stdin
+// takes the place of a skipped file in a pipeline, where leaving out the
+// output would lose the input.
+func copyTemplate(output string) (bool, error) {
+	in, gzipped, err := readInput(stdinArg)
+	if err != nil || !isTemplate(in) {
+		return false, err
+	}
+	log.Printf("%s: contains template directives, written unchanged", displayName(stdinArg))
+	if err := dumpStream(bytes.NewBuffer(in), output, compressOutput(stdinArg, output, gzipped)); err != nil {
+		return false, fmt.Errorf("Cannot write output: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+)
+
+func TestTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	chart := filepath.Join(dir, "deployment.yaml.gotmpl")
+	workflow := filepath.Join(dir, "workflow.yaml")
+	assert.NoError(t, ioutil.WriteFile(chart, []byte("metadata:\n  name: {{ .Release.Name }}\n{{- if .Values.labels }}\n  labels: {}\n{{- end }}\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(workflow, []byte("run: echo ${{ github.sha }}\n"), 0644))
+
+	files, err := withoutTemplates([]string{chart, workflow})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{workflow}, files)
+
+	_, err = formatFile(chart, "", true, false, format.DefaultOptions())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "the file contains template directives")
+}
+
+func TestTemplatesStdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	chart := filepath.Join(dir, "chart.yaml")
+	plain := filepath.Join(dir, "plain.yaml")
+	out := filepath.Join(dir, "out.yaml")
+	assert.NoError(t, ioutil.WriteFile(chart, []byte("a: {{ .Values.x }}\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(plain, []byte("b: 1\na: 2\n"), 0644))
+
+	defer func(f *os.File) { os.Stdin, stdin = f, nil }(os.Stdin)
+	for f, expected := range map[string]string{chart: "a: {{ .Values.x }}\n", plain: "a: 2\nb: 1\n"} {
+		stdin = nil
+		os.Stdin, err = os.Open(f)
+		assert.NoError(t, err)
+		defer os.Stdin.Close()
+
+		files, err := withoutTemplates([]string{stdinArg})
+		assert.NoError(t, err)
+		copied, err := copyTemplate(out)
+		assert.NoError(t, err)
+		assert.Equal(t, f == chart, copied)
+		assert.Equal(t, f == chart, len(files) == 0)
+		if !copied {
+			// Stdin is read again after it has been checked.
+			_, err = formatFile(stdinArg, out, false, false, format.DefaultOptions())
+			assert.NoError(t, err)
+		}
+		content, err := ioutil.ReadFile(out)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+}
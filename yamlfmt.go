@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"flag"
 	"fmt"
@@ -9,311 +10,637 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
-	"strconv"
 	"strings"
 
-	"gopkg.in/yaml.v3"
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
 )
 
-type queueItem struct {
-	Node *yaml.Node
-	Path []string
-	Indent int
-}
-
-type tupleItem struct {
-	Key *yaml.Node
-	Value *yaml.Node
-}
-
 func main() {
 	overwrite := flag.Bool("w", false, "overwrite the input file")
+	canonical := flag.Bool("canonical", false, "write a canonical form without comments, for example for hashing, ignoring the flags for layout and styles")
 	indent := flag.Int("indent", 2, "default indent")
 	debug := flag.Bool("d", false, "show debug output on stderr")
+	debugFormat := flag.String("debug-format", "text", "format of the debug output, text or json")
+	sortNamespace := flag.Bool("sort-namespace", true, "sort documents of the same kind by metadata.namespace")
+	sortName := flag.Bool("sort-name", true, "sort documents of the same kind and namespace by metadata.name")
+	sortAnnotation := flag.String("sort-annotation", "", "order documents that are equal by kind, namespace and name by the number in this annotation, such as config.kubernetes.io/index")
+	sortDocs := flag.String("sort-docs", "all", "sort documents by kind, namespace and name (all), in reverse (reverse), only within kinds kept in first-seen order (group), or not at all (none)")
+	dedupDocs := flag.Bool("dedup-docs", false, "drop documents that are equal to an earlier one")
+	dedupIgnoreComments := flag.Bool("dedup-ignore-comments", false, "with -dedup-docs, also drop documents that only differ in comments or styles")
+	mergeDocs := flag.Bool("merge-docs", false, "deep-merge documents with the same kind, namespace and name into the first of them")
+	mergeSeqs := flag.String("merge-seqs", "replace", "with -merge-docs, replace sequences by later ones (replace) or concatenate them (append)")
+	sortKeys := flag.String("sort-keys", "asc", "sort keys in ascending (asc) or descending (desc) order")
+	sortLabelsByName := flag.Bool("sort-keys-ignore-case-and-prefix", false, "sort the keys of metadata.labels and metadata.annotations by their name after the last /, then by their prefix, ignoring case")
+	keepRegions := flag.Bool("keep-regions", false, "only sort keys within the regions delimited by # region and # endregion comments")
+	sortDepth := flag.Int("sort-depth", -1, "only sort the keys of mappings nested at most this many levels deep (0 for no sorting, negative for no limit)")
+	noStyleNormalize := flag.Bool("no-style-normalize", false, "keep the quoting and flow style of the input")
+	keepFlow := flag.Bool("keep-flow", false, "keep flow style sequences and mappings")
+	keepFlowMax := flag.Int("keep-flow-max", 0, "with -keep-flow, only keep flow sequences of at most this many items (0 for no limit)")
+	flowFoldThreshold := flag.Int("flow-fold-threshold", 0, "keep flow sequences of at most this many items inline and turn longer ones into block style (0 to disable)")
+	seqStyle := flag.String("seq-style", "", "render all sequences in block or flow style (default: keep or normalize the input style)")
+	seqIndentStyle := flag.String("seq-indent-style", "", "place the dashes of sequences under keys flush with the key (flush), indented (indent), or flush at the first level and indented in sequences (nested) (default: indented by indent-2)")
+	indentStyle := flag.String("indent-style", "space", "indent with spaces (space), or with a tab per -indent spaces (tab), which is not valid YAML and only meant for tools that accept it")
+	indentlessSeq := flag.Bool("indentless-seq", false, "place the dashes of sequences under keys flush with the key at every level, the same as -seq-indent-style flush")
+	minimalDiff := flag.Bool("minimal-diff", false, "experimental: keep the source text of top-level entries that are unchanged apart from comments and styles")
+	pruneNull := flag.Bool("prune-null", false, "remove mapping entries whose value is null")
+	pruneEmpty := flag.Bool("prune-empty", false, "remove mapping entries whose value is an empty mapping or sequence")
+	allowPartial := flag.Bool("allow-partial", false, "output the documents before a syntax error and skip documents that fail to format, except with -w")
+	expandAliases := flag.Bool("expand-aliases", false, "replace aliases and merge keys by the content they refer to")
+	normalizeFloats := flag.Bool("normalize-floats", false, "write floats in a canonical form such as 0.5 and 1000.0")
+	nullStyle := flag.String("null-style", "null", "write nulls as null, tilde (~) or empty")
+	stripComments := flag.Bool("strip-comments", false, "remove all comments, except for the header comments of documents with keepHeaderComment in the configuration")
+	normalizeComments := flag.Bool("normalize-comments", false, "put exactly one space after the # of comments such as #foo")
+	trimStrings := flag.Bool("trim-strings", false, "remove spaces around string values")
+	explicitStart := flag.Bool("explicit-start", false, "start the first document with a --- marker even if the input doesn't")
+	explicitEnd := flag.Bool("explicit-end", false, "end every document with a ... marker")
+	checkSemantics := flag.Bool("check-semantics", false, "fail if formatting would change the decoded data")
+	reportIndent := flag.Bool("report-indent", false, "warn about documents indented by another number of spaces than -indent")
+	rejectTabs := flag.Bool("reject-tabs", true, "explain syntax errors caused by tabs in the indentation")
+	subtree := flag.String("path", "", "only format the node at this dotted path, such as spec.template.spec, in each document")
+	set := setFlag{}
+	flag.Var(set, "set", "set the scalar at a dotted path to a value, such as metadata.namespace=prod, in each document (repeatable)")
+	from := flag.String("from", "yaml", "format of the input, yaml or json")
+	output := flag.String("o", "", "write the output to this file, or into this directory with multiple inputs, instead of stdout")
+	onlyChanged := flag.Bool("only-changed", false, "format the YAML files in the working directory that git reports as modified or staged, instead of file arguments")
+	forceStdin := flag.Bool("stdin", false, "read stdin and refuse file arguments")
+	toStdout := flag.Bool("stdout", false, "write all inputs to stdout as a single stream separated by ---")
+	jobs := flag.Int("jobs", 1, "with -stdout, format up to this many files concurrently")
+	alwaysWrite := flag.Bool("always-write", false, "with -w, also rewrite files that are already formatted")
+	lint := flag.Bool("lint", false, "instead of formatting, report tab indentation, trailing whitespace, colliding keys and plain values that YAML 1.1 reads as booleans, as file:line: message")
+	dryRun := flag.Bool("dry-run", false, "report which files would be reformatted without writing anything")
+	report := flag.String("report", "", "instead of writing files, write a report of the results to stdout, json")
+	failOnChange := flag.Bool("fail-on-change", false, "exit with status 1 if any file was, or with -dry-run or -report would be, reformatted")
+	templateSafe := flag.Bool("template-safe", false, "skip files that contain Go template directives such as {{ .Values.name }}, and write such stdin unchanged")
+	noFollowSymlinks := flag.Bool("no-follow-symlinks", false, "skip files that are symbolic links instead of formatting their targets")
+	allowEmpty := flag.Bool("allow-empty", false, "do nothing instead of failing if a glob pattern matches no files")
+	includeHidden := flag.Bool("include-hidden", false, "let glob patterns match hidden files and directories")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile to this file")
+	memProfile := flag.String("memprofile", "", "write a heap profile to this file when done")
+	configFile := flag.String("config", "", "configuration file (default $"+configEnv+", or "+defaultConfigFile+" if present)")
 	flag.Parse()
 
-	if flag.NArg() > 0 {
-		for _, f := range flag.Args() {
-			formatFile(f, *indent, *overwrite, *debug)
+	stopProfiles, err := startProfiles(*cpuProfile, *memProfile)
+	if err != nil {
+		log.Fatalf("Cannot start profiling: %v", err)
+	}
+	finish := func() {
+		if e := stopProfiles(); e != nil {
+			log.Fatalf("Cannot write profile: %v", e)
 		}
-	} else {
-		formatStream(os.Stdin, os.Stdout, *indent, *debug)
 	}
-}
+	defer finish()
 
-func formatFile(f string, indent int, overwrite bool, debug bool) {
-	r, err := os.Open(f)
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Cannot load configuration: %v", err)
+	}
+
+	opts := format.DefaultOptions()
+	opts.Indent = *indent
+	opts.Canonical = *canonical
+	opts.Debug = *debug
+	opts.DebugFormat = *debugFormat
+	opts.DebugOutput = os.Stderr
+	opts.SortDocuments = *sortDocs != "none"
+	opts.GroupDocuments = *sortDocs == "group"
+	opts.ReverseDocuments = *sortDocs == "reverse"
+	opts.SortByNamespace = *sortNamespace
+	opts.SortByName = *sortName
+	opts.SortAnnotation = *sortAnnotation
+	opts.DedupDocuments = *dedupDocs
+	opts.DedupIgnoreComments = *dedupIgnoreComments
+	opts.MergeDocuments = *mergeDocs
+	opts.MergeSequences = *mergeSeqs
+	opts.DescendingKeys = *sortKeys == "desc"
+	opts.SortLabelsByName = *sortLabelsByName
+	opts.KeepRegions = *keepRegions
+	opts.SortPaths = cfg.SortPaths
+	opts.KeyOrder = cfg.KeyOrder
+	opts.AllowedKeys = cfg.AllowedKeys
+	opts.ValueTransforms = cfg.ValueTransforms
+	opts.KeyTransforms = cfg.KeyTransforms
+	opts.SortSequences = cfg.SortSequences
+	opts.PreserveQuotes = cfg.PreserveQuotesFor
+	opts.SortKeys = *sortDepth != 0
+	if *sortDepth > 0 {
+		opts.SortDepth = *sortDepth
+	}
+	opts.NormalizeStyles = !*noStyleNormalize
+	opts.KeepFlow = *keepFlow
+	opts.KeepFlowMax = *keepFlowMax
+	opts.FlowFoldThreshold = *flowFoldThreshold
+	opts.SeqStyle = *seqStyle
+	opts.SeqIndentStyle = *seqIndentStyle
+	opts.IndentStyle = *indentStyle
+	opts.MinimalDiff = *minimalDiff
+	opts.AllowPartial = *allowPartial
+	opts.ExpandAliases = *expandAliases
+	opts.NormalizeFloats = *normalizeFloats
+	opts.NullStyle = *nullStyle
+	opts.NormalizeComments = *normalizeComments
+	if cfg.KeepCommentPrefixes != nil {
+		opts.KeepCommentPrefixes = cfg.KeepCommentPrefixes
+	}
+	opts.StripComments = *stripComments
+	opts.KeepHeaderComment = cfg.KeepHeaderComment
+	opts.TrimStrings = *trimStrings
+	opts.ExplicitStart = *explicitStart
+	opts.ExplicitEnd = *explicitEnd
+	opts.CheckSemantics = *checkSemantics
+	opts.Path = *subtree
+	opts.Set = set
+	opts.ReportIndent = *reportIndent
+	opts.RejectTabs = *rejectTabs
+	opts.FromJSON = *from == "json"
+	opts.PruneNull = *pruneNull
+	opts.PruneEmpty = *pruneEmpty
+
+	// Check for file arguments before expanding them, so that patterns that
+	// match nothing with -allow-empty don't read stdin instead.
+	if *forceStdin && flag.NArg() > 0 {
+		log.Fatalf("-stdin cannot be combined with file arguments, got %q", flag.Args())
+	}
+	if *onlyChanged && (flag.NArg() > 0 || *forceStdin) {
+		log.Fatal("-only-changed cannot be combined with file arguments or -stdin")
+	}
+	fromStdin := flag.NArg() == 0 && !*onlyChanged
+	args, err := expandArgs(flag.Args(), *includeHidden, *allowEmpty)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	var out bytes.Buffer
-	if e := formatStream(r, &out, indent, debug); e != nil {
-		log.Fatalf("Failed formatting YAML stream: %v", e)
+	if *onlyChanged {
+		if args, err = changedFiles("."); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *noFollowSymlinks {
+		args = withoutSymlinks(args)
+	}
+	if *templateSafe {
+		if args, err = withoutTemplates(args); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	r.Close()
+	if *debugFormat != "text" && *debugFormat != "json" {
+		log.Fatalf("Unknown -debug-format %q", *debugFormat)
+	}
+	if *sortDocs != "all" && *sortDocs != "reverse" && *sortDocs != "group" && *sortDocs != "none" {
+		log.Fatalf("Unknown -sort-docs %q", *sortDocs)
+	}
+	if *sortKeys != "asc" && *sortKeys != "desc" {
+		log.Fatalf("Unknown -sort-keys %q", *sortKeys)
+	}
+	if *mergeSeqs != "replace" && *mergeSeqs != "append" {
+		log.Fatalf("Unknown -merge-seqs %q", *mergeSeqs)
+	}
+	if *mergeDocs && *checkSemantics {
+		log.Fatal("-merge-docs cannot be combined with -check-semantics")
+	}
+	if *nullStyle != "null" && *nullStyle != "tilde" && *nullStyle != "empty" {
+		log.Fatalf("Unknown -null-style %q", *nullStyle)
+	}
+	if *from != "yaml" && *from != "json" {
+		log.Fatalf("Unknown -from %q", *from)
+	}
+	if *seqStyle != "" && *seqStyle != "block" && *seqStyle != "flow" {
+		log.Fatalf("Unknown -seq-style %q", *seqStyle)
+	}
+	if *report != "" && *report != "json" {
+		log.Fatalf("Unknown -report %q", *report)
+	}
+	if *report != "" && (*overwrite || *dryRun || *output != "" || *toStdout) {
+		log.Fatal("-report cannot be combined with -w, -o, -stdout or -dry-run")
+	}
+	if *lint && (*overwrite || *dryRun || *output != "" || *toStdout || *report != "") {
+		log.Fatal("-lint cannot be combined with -w, -o, -stdout, -dry-run or -report")
+	}
+	if *report != "" && fromStdin {
+		log.Fatal("-report requires file arguments")
+	}
+	if *seqIndentStyle != "" && *seqIndentStyle != "flush" && *seqIndentStyle != "indent" && *seqIndentStyle != "nested" {
+		log.Fatalf("Unknown -seq-indent-style %q", *seqIndentStyle)
+	}
+	if *indentlessSeq && *seqIndentStyle != "" && *seqIndentStyle != "flush" {
+		log.Fatalf("-indentless-seq cannot be combined with -seq-indent-style %q", *seqIndentStyle)
+	}
+	if *indentlessSeq {
+		opts.SeqIndentStyle = "flush"
+	}
+	if *indentStyle != "space" && *indentStyle != "tab" {
+		log.Fatalf("Unknown -indent-style %q", *indentStyle)
+	}
+	if *indentStyle == "tab" && (*minimalDiff || *checkSemantics) {
+		log.Fatal("-indent-style tab cannot be combined with -minimal-diff or -check-semantics")
+	}
+	if *canonical && *subtree != "" {
+		log.Fatal("-canonical cannot be combined with -path")
+	}
+	if *indentStyle == "tab" && !*canonical {
+		log.Print("Warning: -indent-style tab writes non-standard YAML, which YAML parsers, including yamlfmt, reject")
+	}
+	if *dryRun && *overwrite {
+		log.Fatal("-dry-run cannot be combined with -w")
+	}
+	if *dryRun && fromStdin {
+		log.Fatal("-dry-run requires file arguments")
+	}
+	if *overwrite && fromStdin {
+		log.Fatal("-w requires file arguments")
+	}
+	if *output != "" && *overwrite {
+		log.Fatal("-o cannot be combined with -w")
+	}
+	if *output != "" && len(args) > 1 && !isDir(*output) {
+		log.Fatal("-o must name a directory when formatting multiple files")
+	}
+	if *output != "" {
+		if e := checkOutputs(args, *output); e != nil {
+			log.Fatal(e)
+		}
+	}
+	if *toStdout && (*overwrite || *dryRun || *output != "") {
+		log.Fatal("-stdout cannot be combined with -w, -o or -dry-run")
+	}
+	if *failOnChange && (fromStdin || *toStdout) {
+		log.Fatal("-fail-on-change requires file arguments and cannot be combined with -stdout")
+	}
 
-	if e := dumpStream(&out, f, overwrite); e != nil {
-		log.Fatalf("Cannot overwrite: %v", e)
+	if *lint {
+		files := args
+		if fromStdin {
+			files = []string{stdinArg}
+		}
+		found, e := lintFiles(files, os.Stdout)
+		if e != nil {
+			log.Fatal(e)
+		}
+		if found {
+			finish()
+			os.Exit(1)
+		}
+	} else if *report != "" {
+		anyChanged, e := reportFiles(args, os.Stdout, opts)
+		if e != nil {
+			log.Fatal(e)
+		}
+		if *failOnChange && anyChanged {
+			finish()
+			os.Exit(1)
+		}
+	} else if *toStdout && !fromStdin {
+		if e := concatFiles(args, os.Stdout, *jobs, opts); e != nil {
+			log.Fatal(e)
+		}
+	} else if !fromStdin && !*overwrite && !*dryRun && *output == "" {
+		anyChanged, e := printFiles(args, os.Stdout, opts)
+		if e != nil {
+			log.Fatal(e)
+		}
+		if *failOnChange && anyChanged {
+			finish()
+			os.Exit(1)
+		}
+	} else if !fromStdin {
+		anyChanged := false
+		for _, f := range args {
+			changed, e := formatFile(f, outputFile(f, *output, *overwrite), *dryRun, *alwaysWrite, opts)
+			if e != nil {
+				log.Fatal(e)
+			}
+			if *dryRun {
+				fmt.Println(verdict(f, changed))
+			}
+			anyChanged = anyChanged || changed
+		}
+		if *failOnChange && anyChanged {
+			finish()
+			os.Exit(1)
+		}
+	} else {
+		copied := false
+		if *templateSafe {
+			if copied, err = copyTemplate(outputFile(stdinArg, *output, false)); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if !copied {
+			if _, e := formatFile(stdinArg, outputFile(stdinArg, *output, false), false, *alwaysWrite, opts); e != nil {
+				log.Fatal(e)
+			}
+		}
 	}
 }
 
-func formatStream(r io.Reader, out io.Writer, indent int, debug bool) error {
-	d := yaml.NewDecoder(r)
-	in := &yaml.Node{}
-	err := d.Decode(in)
-	docs := []*yaml.Node{}
+// setFlag collects the path=value arguments of repeated -set flags.
+type setFlag map[string]string
 
-	for err == nil {
-		docs = append(docs, in)
-		in = &yaml.Node{}
-		err = d.Decode(in)
+func (s setFlag) String() string {
+	assignments := []string{}
+	for p, v := range s {
+		assignments = append(assignments, p+"="+v)
 	}
+	sort.Strings(assignments)
+	return strings.Join(assignments, ",")
+}
 
-	if err != nil && err != io.EOF {
-		return err
+func (s setFlag) Set(assignment string) error {
+	i := strings.Index(assignment, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected path=value, got %q", assignment)
 	}
+	s[assignment[:i]] = assignment[i+1:]
+	return nil
+}
 
-	sort.Slice(docs, func(i, j int) bool {
-		return sortDocument(docs[i], docs[j])
-	});
+// stdinArg is the file argument that stands for stdin.
+const stdinArg = "-"
 
-	/* node, err2 := traverse(&in, "metadata", "name");
-	if err2 != nil {
-		fmt.Println(err2)
+// readInput reads the file f, or stdin if f is stdinArg, and decompresses
+// it if it is gzip-compressed, which it reports.
+func readInput(f string) ([]byte, bool, error) {
+	var in []byte
+	var err error
+	if f == stdinArg {
+		in, err = readStdin()
 	} else {
-		fmt.Println(node.Value)
-	} */
-
-	e := yaml.NewEncoder(out)
-	e.SetIndent(indent)
+		in, err = ioutil.ReadFile(f)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return decompress(in)
+}
 
-	for _, doc := range docs {
-		normalize(doc, debug)
-		if err := e.Encode(doc); err != nil {
-			log.Fatal(err)
+// stdin holds what readStdin has read from stdin.
+var stdin []byte
+
+// readStdin reads stdin, which can only be read once, and returns the same
+// content when called again, so that -template-safe can look at it before
+// it is formatted.
+func readStdin() ([]byte, error) {
+	if stdin == nil {
+		in, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
 		}
+		stdin = in
 	}
-
-	e.Close()
-
-	return nil
+	return stdin, nil
 }
 
-func sortDocument(i *yaml.Node, j *yaml.Node) bool {
-	kind_i, err_kind_i := traverse(i, "kind")
-	kind_j, err_kind_j := traverse(j, "kind")
-	if err_kind_i != nil && err_kind_j == nil {
-		return false
-	} else if err_kind_j != nil && err_kind_i == nil {
-		return true
-	} else if err_kind_i == nil && err_kind_j == nil && kind_i.Value != kind_j.Value {
-		return kind_i.Value < kind_j.Value
-	}
-
-	ns_i, err_ns_i := traverse(i, "metadata", "namespace")
-	ns_j, err_ns_j := traverse(j, "metadata", "namespace")
-	if err_ns_i != nil && err_ns_j == nil {
-		return false
-	} else if err_ns_j != nil && err_ns_i == nil {
-		return true
-	} else if err_ns_i == nil && err_ns_j == nil && ns_i.Value != ns_j.Value {
-		return ns_i.Value < ns_j.Value
-	}
-
-	name_i, err_i := traverse(i, "metadata", "name")
-	name_j, err_j := traverse(j, "metadata", "name")
-	if err_i != nil && err_j == nil {
-		return false
-	} else if err_j != nil && err_i == nil {
-		return true
-	} else if err_i == nil && err_j == nil && name_i.Value != name_j.Value {
-		return name_i.Value < name_j.Value
-	}
-
-	return false;
+// displayName returns the name of the file f in messages.
+func displayName(f string) string {
+	if f == stdinArg {
+		return "<stdin>"
+	}
+	return f
 }
 
-func normalize(node *yaml.Node, debug bool) {
-	stack := []queueItem{
-		queueItem { Node: node, Path: []string{}, Indent: 0 },
+// outputFile returns the file that the formatted content of f is written
+// to, or "" for stdout. Stdin is written to stdout unless output names a
+// file.
+func outputFile(f string, output string, overwrite bool) string {
+	if f == stdinArg && (overwrite || isDir(output)) {
+		return ""
 	}
-	var top queueItem
-	for len(stack) > 0 {
-		top, stack = stack[0], stack[1:]
-		if debug {
-			printNode(top.Node, top.Path, top.Indent)
+	if overwrite {
+		return f
+	}
+	if output != "" && isDir(output) {
+		return filepath.Join(output, filepath.Base(f))
+	}
+	return output
+}
+
+// checkOutputs fails if the formatted content of two of the files would be
+// written to the same file of the directory output, since they have the
+// same name.
+func checkOutputs(files []string, output string) error {
+	written := map[string]string{}
+	for _, f := range files {
+		name := outputFile(f, output, false)
+		if first, ok := written[name]; ok && filepath.Clean(first) != filepath.Clean(f) {
+			return fmt.Errorf("-o: %s and %s would both be written to %s", first, f, name)
 		}
-		normalizeStyle(&top)
+		written[name] = f
+	}
+	return nil
+}
 
-		content := []queueItem{}
+func isDir(f string) bool {
+	info, err := os.Stat(f)
+	return err == nil && info.IsDir()
+}
 
-		if top.Node.Kind & yaml.SequenceNode > 0 {
-			for index, child := range top.Node.Content {
-				path := append([]string{}, top.Path...)
-				content = append(content, queueItem { Node: child, Path: append(path, strconv.Itoa(index)), Indent: top.Indent + 1 })
-			}
-		} else if top.Node.Kind & yaml.MappingNode > 0 {
-			tuples, _ := tuples(top.Node.Content)
-			for _, tuple := range tuples {
-				content = append(content, queueItem { Node: tuple.Key, Path: top.Path, Indent: top.Indent + 1 })
-				path := append([]string{}, top.Path...)
-				content = append(content, queueItem { Node: tuple.Value, Path: append(path, tuple.Key.Value), Indent: top.Indent + 1 })
-			}
-			sort.Slice(tuples, func(i, j int) bool {
-				return tuples[i].Key.Value < tuples[j].Key.Value
-			});
-			top.Node.Content = contents(tuples)
-		} else {
-			for _, child := range top.Node.Content {
-				content = append(content, queueItem { Node: child, Path: top.Path, Indent: top.Indent + 1 })
-			}
+// withoutSymlinks returns the files that aren't symbolic links. Symbolic
+// links are otherwise followed, so that -w rewrites their targets and keeps
+// the links.
+func withoutSymlinks(files []string) []string {
+	kept := []string{}
+	for _, f := range files {
+		if info, err := os.Lstat(f); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			continue
 		}
-
-		stack = append(content, stack...)
+		kept = append(kept, f)
 	}
+	return kept
 }
 
-func normalizeStyle(item *queueItem) {
-	if item.Node.Style & yaml.SingleQuotedStyle > 0 {
-		item.Node.Style = item.Node.Style ^ yaml.SingleQuotedStyle
-	}
-	if item.Node.Style & yaml.DoubleQuotedStyle > 0 {
-		item.Node.Style = item.Node.Style ^ yaml.DoubleQuotedStyle
-	}
-	if item.Node.Style & yaml.FlowStyle > 0 {
-		item.Node.Style = item.Node.Style ^ yaml.FlowStyle
+// formatFile formats the file f, writes the result to the file output, or
+// to stdout if output is empty, and reports whether the formatted content
+// differs from the input. With dryRun, the result is discarded. Unless
+// alwaysWrite is set, f isn't overwritten with an unchanged result, which
+// would only update its modification time. With AllowPartial, the documents
+// that could be formatted are still written, unless that would overwrite f.
+// A gzip-compressed f is decompressed, and the output is compressed if its
+// name ends with ".gz" or it overwrites a compressed f.
+func formatFile(f string, output string, dryRun bool, alwaysWrite bool, opts format.Options) (bool, error) {
+	in, gzipped, err := readInput(f)
+	if err != nil {
+		return false, err
 	}
-}
+	opts.Warn = warner(f)
+	compress := compressOutput(f, output, gzipped)
 
-func mapping(s []*yaml.Node) (map[string]*yaml.Node, error) {
-	i := 0
-	r := make(map[string]*yaml.Node)
-	if len(s) % 2 != 0 {
-		return r, errors.New("Mapping expected even number of nodes")
+	// Overwriting f requires the complete output before writing anything,
+	// and a dry run discards it, so only output elsewhere is streamed.
+	if !dryRun && output != f {
+		return streamFile(f, in, output, compress, opts)
 	}
-	for i < len(s) {
-		r[s[i].Value] = s[i + 1]
-		i += 2
+
+	var out bytes.Buffer
+	if e := format.Format(bytes.NewReader(in), &out, opts); e != nil {
+		return false, fmt.Errorf("Failed formatting YAML stream: %w%s", fileError(f, e), templateHint(in))
 	}
-	return r, nil
-}
 
-func tuples(s []*yaml.Node) ([]tupleItem, error) {
-	i := 0
-	r := []tupleItem{}
-	if len(s) % 2 != 0 {
-		return r, errors.New("Tuples expected even number of nodes")
+	changed := !bytes.Equal(in, out.Bytes())
+	if dryRun || !changed && !alwaysWrite {
+		return changed, nil
 	}
-	for i < len(s) {
-		r = append(r, tupleItem{ Key: s[i], Value: s[i + 1] })
-		i += 2
+
+	if e := dumpStream(&out, output, compress); e != nil {
+		return changed, fmt.Errorf("Cannot write output: %v", e)
 	}
-	return r, nil
+	return changed, nil
 }
 
-func contents(s []tupleItem) []*yaml.Node {
-	r := []*yaml.Node{}
-	for _, i := range s {
-		r = append(r, i.Key)
-		r = append(r, i.Value)
+// concatFiles formats the files, up to jobs of them concurrently, and writes
+// them to w in the given order as a single stream, separated as by
+// separatedWriter.
+func concatFiles(files []string, w io.Writer, jobs int, opts format.Options) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+	type result struct {
+		out *bytes.Buffer
+		err error
+	}
+	results := make([]chan result, len(files))
+	slots := make(chan bool, jobs)
+	for i, f := range files {
+		results[i] = make(chan result, 1)
+		go func(f string, done chan<- result) {
+			slots <- true
+			out, err := formatInput(f, opts)
+			<-slots
+			done <- result{out, err}
+		}(f, results[i])
+	}
+
+	s := &separatedWriter{w: w}
+	for i := range files {
+		r := <-results[i]
+		if r.err != nil {
+			return r.err
+		}
+		if _, err := io.Copy(s, r.out); err != nil {
+			return err
+		}
+		if err := s.next(); err != nil {
+			return err
+		}
 	}
-	return r
+	return nil
 }
 
-func traverse(node *yaml.Node, keys ...string) (*yaml.Node, error) {
-	i := 0
-	for i < len(keys) {
-		if node.Kind & yaml.DocumentNode > 0 {
-			if len(node.Content) != 1 {
-				return nil, errors.New("Expected one child for DocumentNode")
-			}
-			node = node.Content[0]
-		} else if node.Kind & yaml.SequenceNode > 0 {
-			index, err := strconv.Atoi(keys[i])
-			if err == nil {
-				return nil, errors.New("Traversed to sequence node but got no index")
-			}
-			if index >= len(node.Content) {
-				return nil, errors.New("Traversed to sequence node but index out of range")
-			}
-			node = node.Content[index]
-			i++
-		} else if node.Kind & yaml.MappingNode > 0 {
-			mapping, err := mapping(node.Content)
-			if err != nil {
-				return nil, err
-			}
-			if value, ok := mapping[keys[i]]; ok {
-				node = value
-			} else {
-				return nil, errors.New("Traversed to mapping node but key not in mapping")
-			}
-			i++
-		} else if node.Kind & yaml.ScalarNode > 0 {
-			return nil, errors.New("Traversed to ScalarNode, but not finished yet")
-		} else if node.Kind & yaml.AliasNode > 0 {
-			node = node.Alias
+// printFiles formats the files one after the other and writes them to w as
+// they are formatted, separated as by separatedWriter so that w holds one
+// stream. It reports whether any file isn't formatted.
+func printFiles(files []string, w io.Writer, opts format.Options) (bool, error) {
+	s := &separatedWriter{w: w}
+	defer func(w io.Writer) { stdout = w }(stdout)
+	stdout = s
+	anyChanged := false
+	for _, f := range files {
+		changed, err := formatFile(f, "", false, false, opts)
+		if err != nil {
+			return anyChanged, err
+		}
+		if err := s.next(); err != nil {
+			return anyChanged, err
 		}
+		anyChanged = anyChanged || changed
 	}
-	return node, nil
+	return anyChanged, nil
 }
 
-func printNode(node *yaml.Node, path []string, indent int) {
-	i := 0
-	for i < indent {
-		fmt.Fprint(os.Stderr, "  ")
-		i++
-	}
-	fmt.Fprint(os.Stderr, "Node .")
-	fmt.Fprint(os.Stderr, strings.Join(path, "."))
-	fmt.Fprint(os.Stderr, ": ")
-	fmt.Fprint(os.Stderr, node.Tag)
-	fmt.Fprint(os.Stderr, " ")
-	fmt.Fprint(os.Stderr, node.Value)
-	fmt.Fprint(os.Stderr, " ")
-	if node.Kind & yaml.DocumentNode > 0 {
-		fmt.Fprint(os.Stderr, "DocumentNode ")
-	}
-	if node.Kind & yaml.SequenceNode > 0 {
-		fmt.Fprint(os.Stderr, "SequenceNode ")
-	}
-	if node.Kind & yaml.MappingNode > 0 {
-		fmt.Fprint(os.Stderr, "MappingNode ")
-	}
-	if node.Kind & yaml.ScalarNode > 0 {
-		fmt.Fprint(os.Stderr, "ScalarNode ")
-	}
-	if node.Kind & yaml.AliasNode > 0 {
-		fmt.Fprint(os.Stderr, "AliasNode ")
-	}
-	if node.Style & yaml.TaggedStyle > 0 {
-		fmt.Fprint(os.Stderr, "TaggedStyle ")
+// formatInput formats the file f and returns the result.
+func formatInput(f string, opts format.Options) (*bytes.Buffer, error) {
+	in, _, err := readInput(f)
+	if err != nil {
+		return nil, err
 	}
-	if node.Style & yaml.DoubleQuotedStyle > 0 {
-		fmt.Fprint(os.Stderr, "DoubleQuotedStyle ")
+	opts.Warn = warner(f)
+	var out bytes.Buffer
+	if e := format.Format(bytes.NewReader(in), &out, opts); e != nil {
+		return nil, fmt.Errorf("Failed formatting YAML stream: %w%s", fileError(f, e), templateHint(in))
 	}
-	if node.Style & yaml.SingleQuotedStyle > 0 {
-		fmt.Fprint(os.Stderr, "SingleQuotedStyle ")
+	return &out, nil
+}
+
+// verdict describes the dry run result for the file f.
+func verdict(f string, changed bool) string {
+	if changed {
+		return f + ": would reformat"
 	}
-	if node.Style & yaml.LiteralStyle > 0 {
-		fmt.Fprint(os.Stderr, "LiteralStyle ")
+	return f + ": unchanged"
+}
+
+// warner returns a function that logs warnings about the file f.
+func warner(f string) func(string) {
+	return func(message string) {
+		log.Printf("%s: %s", displayName(f), message)
 	}
-	if node.Style & yaml.FoldedStyle > 0 {
-		fmt.Fprint(os.Stderr, "FoldedStyle ")
+}
+
+var yamlLineError = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// fileError prefixes a formatting error with the file name f and, if the
+// error names one, the line it occurred on, e.g. "config.yaml:12: did not
+// find expected key". A *format.FormatError gets f as its File, and a syntax
+// error keeps being described by its line. Errors joined by errors.Join are
+// prefixed one by one.
+func fileError(f string, err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := []error{}
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, fileError(f, e))
+		}
+		return errors.Join(errs...)
+	}
+	f = displayName(f)
+	var formatErr *format.FormatError
+	if errors.As(err, &formatErr) {
+		formatErr.File = f
+		if m := yamlLineError.FindStringSubmatch(formatErr.Cause.Error()); m != nil && formatErr.Syntax {
+			return &syntaxError{formatErr, fmt.Sprintf("%s:%s: %s", f, m[1], m[2])}
+		}
+		return formatErr
 	}
-	if node.Style & yaml.FlowStyle > 0 {
-		fmt.Fprint(os.Stderr, "FlowStyle ")
+	msg := err.Error()
+	if m := yamlLineError.FindStringSubmatch(msg); m != nil {
+		return fmt.Errorf("%s:%s: %s", f, m[1], m[2])
 	}
-	fmt.Fprintln(os.Stderr, "")
+	return fmt.Errorf("%s: %s", f, strings.TrimPrefix(msg, "yaml: "))
 }
 
-func dumpStream(out *bytes.Buffer, f string, overwrite bool) error {
-	if overwrite {
-		return ioutil.WriteFile(f, out.Bytes(), 0744)
+// syntaxError is a *format.FormatError for a syntax error, described by the
+// file and line that it is on.
+type syntaxError struct {
+	*format.FormatError
+	msg string
+}
+
+func (e *syntaxError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the *format.FormatError of e.
+func (e *syntaxError) Unwrap() error {
+	return e.FormatError
+}
+
+// dumpStream writes out to the file f, compressed with compress, or to
+// stdout if f is empty.
+func dumpStream(out *bytes.Buffer, f string, compress bool) error {
+	if f != "" && compress {
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		if _, err := w.Write(out.Bytes()); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(f, compressed.Bytes(), 0644)
+	}
+	if f != "" {
+		return ioutil.WriteFile(f, out.Bytes(), 0644)
 	}
-	_, err := io.Copy(os.Stdout, out)
+	_, err := io.Copy(stdout, out)
 	return err
 }
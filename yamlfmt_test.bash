@@ -16,34 +16,110 @@ EOF
 gold=$(mktemp)
 cat <<EOF > $gold
 repos:
-- repo: local
-  hooks:
-  - id: documentation_checker
-    name: documentation_checker
-    entry: bash ./scripts/pre-commit/docgen.sh
+- hooks:
+  - entry: bash ./scripts/pre-commit/docgen.sh
+    id: documentation_checker
     language: system
-- repo: https://github.com/pre-commit/mirrors-yapf
-  rev: v0.29.0
-  hooks:
+    name: documentation_checker
+  repo: local
+- hooks:
   - id: yapf
+  repo: https://github.com/pre-commit/mirrors-yapf
+  rev: v0.29.0
 EOF
 
 go install
 test $? -eq 0 || { echo "Failed to compile"; exit 1; }
 
-go run yamlfmt.go -w $file
+go run . -w $file
 test $? -eq 0 || { echo "Failed to run with replace mode"; exit 1; }
 
 cmp $file $gold || { echo "Unexpected output"; diff $file $gold; exit 1; }
 
 out=$(mktemp)
-cat $file | go run yamlfmt.go > $out
+cat $file | go run . > $out
 test $? -eq 0 || { echo "Failed to run reading stdin"; exit 1; }
 
 cmp $out $gold || { echo "Unexpected output"; diff $out $gold; exit 1; }
 
+cat $file | go run . -stdin > $out
+test $? -eq 0 || { echo "Failed to run with -stdin"; exit 1; }
+
+cmp $out $gold || { echo "Unexpected output with -stdin"; diff $out $gold; exit 1; }
+
+go run . -stdin -w $file < /dev/null 2> $out
+test $? -eq 1 || { echo "Expected -stdin to refuse file arguments"; exit 1; }
+grep -q -- "-stdin cannot be combined with file arguments" $out || { echo "Unexpected message"; cat $out; exit 1; }
+
 file1=$(mktemp)
 cp $file $file1
-go run yamlfmt.go -w $file $file1 || { echo "Failed to replace multiple files"; exit 1; }
+go run . -w $file $file1 || { echo "Failed to replace multiple files"; exit 1; }
 
 cmp $file1 $gold || { echo "Unexpected output from replacing multiple files"; diff $file1 $gold; exit 1; }
+
+go run . -w -fail-on-change $file1
+test $? -eq 0 || { echo "Failed on a file that was not reformatted"; exit 1; }
+
+file2=$(mktemp)
+printf 'b: 1\na: 2\n' > $file2
+go run . -w -fail-on-change $file2 2> /dev/null
+test $? -eq 1 || { echo "Expected exit status 1 for a reformatted file"; exit 1; }
+
+go run . -w "$(dirname $file2)/no-such-dir/*.yaml" 2> /dev/null
+test $? -eq 1 || { echo "Expected exit status 1 for a pattern that matches nothing"; exit 1; }
+
+go run . -w -allow-empty "$(dirname $file2)/no-such-dir/*.yaml" < /dev/null
+test $? -eq 0 || { echo "Failed with -allow-empty on a pattern that matches nothing"; exit 1; }
+
+seq=$(mktemp)
+printf 'spec:\n  containers:\n  - name: web\n    ports: [80]\n' > $seq
+seqgold=$(mktemp)
+printf 'spec:\n    containers:\n        - name: web\n          ports:\n              - 80\n' > $seqgold
+go run . -indent 4 -seq-indent-style indent $seq > $out
+cmp $out $seqgold || { echo "Unexpected output with -seq-indent-style indent"; diff $out $seqgold; exit 1; }
+
+printf 'spec:\n    containers:\n    - name: web\n      ports:\n      - 80\n' > $seqgold
+go run . -indent 4 -indentless-seq $seq > $out
+cmp $out $seqgold || { echo "Unexpected output with -indentless-seq"; diff $out $seqgold; exit 1; }
+
+go run . -indentless-seq -seq-indent-style indent $seq 2> /dev/null
+test $? -eq 1 || { echo "Expected -indentless-seq to refuse -seq-indent-style indent"; exit 1; }
+
+# Like gofmt: files are printed to stdout in order, separated by ---, and
+# left as they are without -w, overwritten silently with -w, and -w can't overwrite stdin.
+unformatted=$(mktemp)
+printf 'b: 1\na: 2\n' > $unformatted
+cp $unformatted $file2
+go run . $unformatted $file2 > $out
+test $? -eq 0 || { echo "Failed to print multiple files"; exit 1; }
+printf 'a: 2\nb: 1\n---\na: 2\nb: 1\n' | cmp - $out || { echo "Unexpected output for multiple files"; cat $out; exit 1; }
+printf 'b: 1\na: 2\n' | cmp - $unformatted || { echo "Printing a file changed it"; exit 1; }
+
+go run . -w $unformatted > $out
+test $? -eq 0 || { echo "Failed to overwrite a file"; exit 1; }
+test -s $out && { echo "Unexpected output with -w"; cat $out; exit 1; }
+printf 'a: 2\nb: 1\n' | cmp - $unformatted || { echo "Unexpected content with -w"; cat $unformatted; exit 1; }
+
+printf 'b: 1\n' | go run . -w 2> $out
+test $? -eq 1 || { echo "Expected -w to refuse stdin"; exit 1; }
+grep -q -- "-w requires file arguments" $out || { echo "Unexpected message"; cat $out; exit 1; }
+
+lint=$(mktemp)
+printf 'a: yes \n' > $lint
+go run . -lint $lint > $out 2> /dev/null
+test $? -eq 1 || { echo "Expected exit status 1 for -lint issues"; exit 1; }
+printf '%s:1: trailing whitespace\n%s:1: value yes is a string in YAML 1.2 but a boolean in YAML 1.1, quote it\n' $lint $lint | cmp - $out || { echo "Unexpected -lint output"; cat $out; exit 1; }
+printf 'a: 1\n' | go run . -lint > $out
+test $? -eq 0 || { echo "Failed -lint on a clean stream"; exit 1; }
+test -s $out && { echo "Unexpected -lint output"; cat $out; exit 1; }
+printf 'a: yes \n' | cmp - $lint || { echo "-lint changed the file"; exit 1; }
+
+warning=$(mktemp)
+printf 'a:\n  b: |\n    text\n' | go run . -indent 4 -indent-style tab 2> $warning > $out
+test $? -eq 0 || { echo "Failed with -indent-style tab"; exit 1; }
+printf 'a:\n\tb: |\n        text\n' | cmp - $out || { echo "Unexpected output with -indent-style tab"; cat $out; exit 1; }
+grep -q "non-standard YAML" $warning || { echo "Expected a warning with -indent-style tab"; exit 1; }
+
+printf 'b: 1\na: 2\n' | gzip -c | go run . > $out
+test $? -eq 0 || { echo "Failed to format gzip-compressed stdin"; exit 1; }
+printf 'a: 2\nb: 1\n' | cmp - $out || { echo "Unexpected output for gzip-compressed stdin"; cat $out; exit 1; }
@@ -2,36 +2,336 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/wangkuiyi/yamlfmt/pkg/format"
+	"gopkg.in/yaml.v3"
 )
 
-func TestFormatStream(t *testing.T) {
+func TestFormatFileSyntaxError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(f, []byte("a: 1\nb:\n  c: 2\n d: 3\n"), 0644))
+
+	_, err = formatFile(f, "", false, false, format.DefaultOptions())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), f+":3: did not find expected key")
+}
+
+func TestFormatFileReportIndent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(f, []byte("a:\n    b: 1\n"), 0644))
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+	opts := format.DefaultOptions()
+	opts.ReportIndent = true
+	_, err = formatFile(f, "", true, false, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, stderr.String(), f+": document 1: indented by 4 spaces instead of 2\n")
+}
+
+func TestFormatFileFormatError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(f, []byte("a: 1\n---\nb: 1\nb: 2\n"), 0644))
+
+	_, err = formatFile(f, "", true, false, format.DefaultOptions())
+	var formatErr *format.FormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.Equal(t, f, formatErr.File)
+	assert.Equal(t, 2, formatErr.DocIndex)
+	assert.EqualError(t, err, "Failed formatting YAML stream: "+f+`: document 2: line 4: key b collides with key b on line 3`)
+
+	// With -allow-partial, every error is described.
+	assert.NoError(t, ioutil.WriteFile(f, []byte("a: 1\n---\nb: 1\nb: 2\n---\nc: [\n"), 0644))
+	opts := format.DefaultOptions()
+	opts.AllowPartial = true
+	_, err = formatFile(f, "", true, false, opts)
+	assert.EqualError(t, err, "Failed formatting YAML stream: "+f+`: document 2: line 4: key b collides with key b on line 3`+"\n"+
+		f+":6: did not find expected node content")
+	assert.True(t, errors.As(err, &formatErr))
+}
+
+func TestFormatFileUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f := filepath.Join(dir, "tidy.yaml")
+	assert.NoError(t, ioutil.WriteFile(f, []byte("a: 2\nb: 1\n"), 0644))
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(f, past, past))
+
+	changed, err := formatFile(f, f, false, false, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	info, err := os.Stat(f)
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(past), "modified at %v", info.ModTime())
+
+	_, err = formatFile(f, f, false, true, format.DefaultOptions())
+	assert.NoError(t, err)
+	info, err = os.Stat(f)
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().After(past), "modified at %v", info.ModTime())
+}
+
+func TestFormatFileJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.json")
+	out := filepath.Join(dir, "out.yaml")
+	assert.NoError(t, ioutil.WriteFile(in, []byte(`{"b": [1, 2], "a": {"c": null}}`), 0644))
+
+	opts := format.DefaultOptions()
+	opts.FromJSON = true
+	_, err = formatFile(in, out, false, false, opts)
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "a:\n  c: null\nb:\n- 1\n- 2\n", string(content))
+
+	assert.NoError(t, ioutil.WriteFile(in, []byte("{\n\"a\": 1,\n}\n"), 0644))
+	_, err = formatFile(in, out, false, false, opts)
+	assert.EqualError(t, err, "Failed formatting YAML stream: "+in+":3: invalid JSON: invalid character '}' looking for beginning of object key string")
+}
+
+func TestFormatFileDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	messy := filepath.Join(dir, "messy.yaml")
+	tidy := filepath.Join(dir, "tidy.yaml")
+	assert.NoError(t, ioutil.WriteFile(messy, []byte("b:   1\na: 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(tidy, []byte("a: 2\nb: 1\n"), 0644))
+
+	changed, err := formatFile(messy, "", true, false, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, messy+": would reformat", verdict(messy, changed))
+
+	changed, err = formatFile(tidy, "", true, false, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, tidy+": unchanged", verdict(tidy, changed))
+
+	content, err := ioutil.ReadFile(messy)
+	assert.NoError(t, err)
+	assert.Equal(t, "b:   1\na: 2\n", string(content))
+}
+
+func TestFormatFileOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.yaml")
+	out := filepath.Join(dir, "out.yaml")
+	assert.NoError(t, ioutil.WriteFile(in, []byte("b:   1\na: 2\n"), 0644))
+
+	_, err = formatFile(in, out, false, false, format.DefaultOptions())
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(in)
+	assert.NoError(t, err)
+	assert.Equal(t, "b:   1\na: 2\n", string(content))
+
+	content, err = ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 2\nb: 1\n", string(content))
+
+	assert.Equal(t, filepath.Join(dir, "in.yaml"), outputFile("sub/in.yaml", dir, false))
+	assert.Equal(t, "sub/in.yaml", outputFile("sub/in.yaml", "", true))
+	assert.Equal(t, "", outputFile("sub/in.yaml", "", false))
+
+	// Files with the same name can't be written to the same directory.
+	assert.NoError(t, checkOutputs([]string{"d1/x.yaml", "d2/y.yaml", "./d1/x.yaml"}, dir))
+	assert.EqualError(t, checkOutputs([]string{"d1/x.yaml", "d2/y.yaml", "d2/x.yaml"}, dir),
+		"-o: d1/x.yaml and d2/x.yaml would both be written to "+filepath.Join(dir, "x.yaml"))
+}
+
+func TestFormatFileSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "target.yaml")
+	link := filepath.Join(dir, "link.yaml")
+	assert.NoError(t, ioutil.WriteFile(target, []byte("b:   1\na: 2\n"), 0644))
+	assert.NoError(t, os.Symlink(target, link))
+
+	_, err = formatFile(link, outputFile(link, "", true), false, false, format.DefaultOptions())
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 2\nb: 1\n", string(content))
+
+	info, err := os.Lstat(link)
+	assert.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+
+	assert.Equal(t, []string{target}, withoutSymlinks([]string{target, link}))
+}
+
+func TestFormatFileStdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "stdin.yaml")
+	a := filepath.Join(dir, "a.yaml")
+	assert.NoError(t, ioutil.WriteFile(in, []byte("y: 1\nx: 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(a, []byte("b: 1\na: 2\n"), 0644))
+
+	defer func(f *os.File) { os.Stdin, stdin = f, nil }(os.Stdin)
+	stdin = nil
+	os.Stdin, err = os.Open(in)
+	assert.NoError(t, err)
+	defer os.Stdin.Close()
+
 	var out bytes.Buffer
-	assert.NoError(t, formatStream(strings.NewReader(input), &out, 2))
-	assert.Equal(t, output, out.String())
-}
-
-const (
-	input = `
-apiVersion: apps/v1 # for versions before 1.9.0 use apps/v1beta2
-spec:
-  selector:
-         app:    sqlflow-mysql
----
-apiVersion: v1   
-metadata:
-    name: sqlflow-mysql
-`
-	output = `apiVersion: apps/v1 # for versions before 1.9.0 use apps/v1beta2
-spec:
-  selector:
-    app: sqlflow-mysql
----
-apiVersion: v1
-metadata:
-  name: sqlflow-mysql
-`
-)
+	assert.NoError(t, concatFiles([]string{a, stdinArg}, &out, 1, format.DefaultOptions()))
+	assert.Equal(t, "a: 2\nb: 1\n---\nx: 2\ny: 1\n", out.String())
+
+	assert.Equal(t, "", outputFile(stdinArg, "", true))
+	assert.Equal(t, "", outputFile(stdinArg, dir, false))
+	assert.Equal(t, "out.yaml", outputFile(stdinArg, "out.yaml", false))
+	assert.EqualError(t, fileError(stdinArg, errors.New("yaml: line 2: bad")), "<stdin>:2: bad")
+}
+
+func TestConcatFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	assert.NoError(t, ioutil.WriteFile(a, []byte("kind: Service\n---\nkind: Namespace\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(b, []byte("y: 1\nx: 2\n"), 0644))
+
+	var out bytes.Buffer
+	assert.NoError(t, concatFiles([]string{a, b}, &out, 1, format.DefaultOptions()))
+	assert.Equal(t, "kind: Namespace\n---\nkind: Service\n---\nx: 2\ny: 1\n", out.String())
+}
+
+func TestConcatFilesDirectives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	c := filepath.Join(dir, "c.yaml")
+	assert.NoError(t, ioutil.WriteFile(a, []byte("a: 1\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(b, []byte("%YAML 1.1\n%TAG !e! tag:example.com,2000:\n---\nb: !e!app 1\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(c, []byte("%TAG !e! tag:example.com,2000:\n---\nc: !e!app 1\n"), 0644))
+
+	opts := format.DefaultOptions()
+	var out bytes.Buffer
+	assert.NoError(t, concatFiles([]string{a, b, c}, &out, 1, opts))
+	assert.Equal(t, "a: 1\n"+
+		"...\n%YAML 1.1\n%TAG !e! tag:example.com,2000:\n---\nb: !e!app 1\n"+
+		"...\n%TAG !e! tag:example.com,2000:\n---\nc: !e!app 1\n", out.String())
+
+	d := yaml.NewDecoder(bytes.NewReader(out.Bytes()))
+	tags := []string{}
+	for {
+		var doc yaml.Node
+		if err := d.Decode(&doc); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		tags = append(tags, doc.Content[0].Content[1].Tag)
+	}
+	assert.Equal(t, []string{"!!int", "tag:example.com,2000:app", "tag:example.com,2000:app"}, tags)
+
+	// A file that ends with a "..." marker needs no other one.
+	opts.ExplicitEnd = true
+	out.Reset()
+	assert.NoError(t, concatFiles([]string{a, c}, &out, 1, opts))
+	assert.Equal(t, "a: 1\n...\n%TAG !e! tag:example.com,2000:\n---\nc: !e!app 1\n...\n", out.String())
+}
+
+func TestPrintFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	c := filepath.Join(dir, "c.yaml")
+	d := filepath.Join(dir, "d.yaml")
+	assert.NoError(t, ioutil.WriteFile(a, []byte("y: 1\nx: 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(b, []byte("x: 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(c, []byte("%TAG !e! tag:example.com,2000:\n---\nc: !e!app 1\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(d, []byte("---\nd: 1\n"), 0644))
+
+	var out bytes.Buffer
+	changed, err := printFiles([]string{a, b, c, d}, &out, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "x: 2\ny: 1\n---\nx: 2\n...\n%TAG !e! tag:example.com,2000:\n---\nc: !e!app 1\n---\nd: 1\n", out.String())
+	assert.Equal(t, os.Stdout, stdout)
+
+	out.Reset()
+	changed, err = printFiles([]string{b, b}, &out, format.DefaultOptions())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "x: 2\n---\nx: 2\n", out.String())
+}
+
+func TestConcatFilesJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := []string{}
+	expected := []string{}
+	for i := 0; i < 20; i++ {
+		f := filepath.Join(dir, fmt.Sprintf("%02d.yaml", i))
+		content := strings.Repeat(fmt.Sprintf("---\nname: file-%02d\n", i), 1+(20-i)*50)
+		assert.NoError(t, ioutil.WriteFile(f, []byte(content), 0644))
+		files = append(files, f)
+		expected = append(expected, content)
+	}
+
+	for _, jobs := range []int{1, 4, 20} {
+		var out bytes.Buffer
+		assert.NoError(t, concatFiles(files, &out, jobs, format.DefaultOptions()))
+		assert.Equal(t, strings.Join(expected, ""), out.String(), "jobs %d", jobs)
+	}
+}
+
+func TestSetFlag(t *testing.T) {
+	s := setFlag{}
+	assert.NoError(t, s.Set("metadata.namespace=prod"))
+	assert.NoError(t, s.Set("metadata.labels.url=http://a/?b=c"))
+	assert.Equal(t, setFlag{"metadata.namespace": "prod", "metadata.labels.url": "http://a/?b=c"}, s)
+	assert.Equal(t, "metadata.labels.url=http://a/?b=c,metadata.namespace=prod", s.String())
+	assert.EqualError(t, s.Set("=prod"), `expected path=value, got "=prod"`)
+}